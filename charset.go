@@ -0,0 +1,54 @@
+package emf
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// code pages of character sets, ANSI code page is used for the rest
+var charsetEncodings = map[uint8]encoding.Encoding{
+	ANSI_CHARSET:        charmap.Windows1252,
+	MAC_CHARSET:         charmap.Macintosh,
+	SHIFTJIS_CHARSET:    japanese.ShiftJIS,
+	HANGUL_CHARSET:      korean.EUCKR,
+	GB2312_CHARSET:      simplifiedchinese.GBK,
+	CHINESEBIG5_CHARSET: traditionalchinese.Big5,
+	GREEK_CHARSET:       charmap.Windows1253,
+	TURKISH_CHARSET:     charmap.Windows1254,
+	VIETNAMESE_CHARSET:  charmap.Windows1258,
+	HEBREW_CHARSET:      charmap.Windows1255,
+	ARABIC_CHARSET:      charmap.Windows1256,
+	BALTIC_CHARSET:      charmap.Windows1257,
+	RUSSIAN_CHARSET:     charmap.Windows1251,
+	THAI_CHARSET:        charmap.Windows874,
+	EASTEUROPE_CHARSET:  charmap.Windows1250,
+	OEM_CHARSET:         charmap.CodePage437,
+}
+
+// decodeString decodes 8-bit characters string in the character set
+func decodeString(b []byte, charset uint8) string {
+	if charset == SYMBOL_CHARSET {
+		// symbol fonts map characters to the private use area
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = 0xF000 | rune(c)
+		}
+		return string(r)
+	}
+
+	enc, ok := charsetEncodings[charset]
+	if !ok {
+		enc = charmap.Windows1252
+	}
+
+	s, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		// invalid multibyte sequence
+		s, _ = charmap.Windows1252.NewDecoder().Bytes(b)
+	}
+	return string(s)
+}
@@ -0,0 +1,299 @@
+package emf
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/golang/freetype/raster"
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dbase"
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// clipPainter paints spans onto RGBA image through the clipping mask
+type clipPainter struct {
+	*raster.RGBAPainter
+	// nil mask means no clipping
+	mask *image.Alpha
+	buf  []raster.Span
+	// nothing is painted when discard is set
+	discard bool
+}
+
+func newClipPainter(img *image.RGBA) *clipPainter {
+	return &clipPainter{RGBAPainter: raster.NewRGBAPainter(img)}
+}
+
+func (p *clipPainter) Paint(ss []raster.Span, done bool) {
+	if p.discard {
+		return
+	}
+	if p.mask == nil {
+		p.RGBAPainter.Paint(ss, done)
+		return
+	}
+
+	b := p.mask.Bounds()
+	p.buf = p.buf[:0]
+	for _, s := range ss {
+		if s.Y < b.Min.Y || s.Y >= b.Max.Y {
+			continue
+		}
+		if s.X0 < b.Min.X {
+			s.X0 = b.Min.X
+		}
+		if s.X1 > b.Max.X {
+			s.X1 = b.Max.X
+		}
+
+		// split span into runs with the same mask value
+		for x0 := s.X0; x0 < s.X1; {
+			a := p.mask.Pix[p.mask.PixOffset(x0, s.Y)]
+			x1 := x0 + 1
+			for x1 < s.X1 && p.mask.Pix[p.mask.PixOffset(x1, s.Y)] == a {
+				x1++
+			}
+			if a != 0 {
+				p.buf = append(p.buf, raster.Span{
+					Y: s.Y, X0: x0, X1: x1,
+					Alpha: s.Alpha * uint32(a) / 0xff,
+				})
+			}
+			x0 = x1
+		}
+	}
+
+	p.RGBAPainter.Paint(p.buf, done)
+}
+
+// pathMask rasterizes paths with current transformation into the mask
+func (ctx *context) pathMask(fillRule draw2d.FillRule, paths ...*draw2d.Path) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, ctx.w, ctx.h))
+
+	r := raster.NewRasterizer(ctx.w, ctx.h)
+	r.UseNonZeroWinding = fillRule == draw2d.FillRuleWinding
+
+	tr := ctx.GetMatrixTransform()
+	flattener := draw2dbase.Transformer{Tr: tr, Flattener: draw2dimg.FtLineBuilder{Adder: r}}
+	for _, p := range paths {
+		draw2dbase.Flatten(p, flattener, tr.GetScale())
+	}
+
+	r.Rasterize(raster.NewAlphaSrcPainter(mask))
+	return mask
+}
+
+// rectMask returns mask of the rectangle in logical units
+func (ctx *context) rectMask(rc RectL) *image.Alpha {
+	x1, y1, x2, y2 := float64(rc.Left), float64(rc.Top), float64(rc.Right), float64(rc.Bottom)
+	p := &draw2d.Path{}
+	p.MoveTo(x1, y1)
+	p.LineTo(x2, y1)
+	p.LineTo(x2, y2)
+	p.LineTo(x1, y2)
+	p.Close()
+	return ctx.pathMask(draw2d.FillRuleWinding, p)
+}
+
+// intersectMask returns intersection of two masks, nil mask is not clipped
+func intersectMask(a, b *image.Alpha) *image.Alpha {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	m := image.NewAlpha(a.Bounds())
+	for i := range m.Pix {
+		m.Pix[i] = uint8(uint32(a.Pix[i]) * uint32(b.Pix[i]) / 0xff)
+	}
+	return m
+}
+
+// setClip sets clipping region of device context
+func (ctx *context) setClip(mask *image.Alpha) {
+	ctx.clip = mask
+	ctx.updateClip()
+}
+
+// updateClip sets clipping mask used for drawing,
+// it's an intersection of meta region and clipping region
+func (ctx *context) updateClip() {
+	ctx.painter.mask = intersectMask(ctx.metaClip, ctx.clip)
+}
+
+// clipMask returns clipping mask used for drawing or nil
+func (ctx *context) clipMask() *image.Alpha {
+	return ctx.painter.mask
+}
+
+// deviceMask returns mask of the region in device units
+func (ctx *context) deviceMask(rgn Region) *image.Alpha {
+	ctx.Save()
+	defer ctx.Restore()
+	ctx.SetMatrixTransform(ctx.deviceTransform())
+	return ctx.pathMask(draw2d.FillRuleWinding, rgn.path())
+}
+
+// combineClip combines clipping region with the mask using RGN_* mode
+func (ctx *context) combineClip(mask *image.Alpha, mode uint32) {
+	clip := ctx.clip
+	if clip == nil {
+		// default clipping region is the whole device
+		clip = fullMask(ctx.w, ctx.h)
+	}
+	ctx.setClip(combineMasks(clip, mask, mode))
+}
+
+// fullMask returns mask covering the whole image
+func fullMask(w, h int) *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, w, h))
+	for i := range m.Pix {
+		m.Pix[i] = 0xff
+	}
+	return m
+}
+
+// combineMasks returns combination of two masks using RGN_* mode
+func combineMasks(a, b *image.Alpha, mode uint32) *image.Alpha {
+	m := image.NewAlpha(a.Bounds())
+	for i := range m.Pix {
+		a, b := uint32(a.Pix[i]), uint32(b.Pix[i])
+		var v uint32
+		switch mode {
+		case RGN_AND:
+			v = a * b / 0xff
+		case RGN_OR:
+			v = a + b - a*b/0xff
+		case RGN_XOR:
+			v = a + b - 2*a*b/0xff
+		case RGN_DIFF:
+			v = a * (0xff - b) / 0xff
+		case RGN_COPY:
+			v = b
+		}
+		m.Pix[i] = uint8(v)
+	}
+	return m
+}
+
+// offsetMask returns mask moved by dx, dy pixels
+func offsetMask(m *image.Alpha, dx, dy int) *image.Alpha {
+	o := image.NewAlpha(m.Bounds())
+	draw.Draw(o, m.Bounds().Add(image.Pt(dx, dy)), m, m.Bounds().Min, draw.Src)
+	return o
+}
+
+// fillMask fills pixels of the mask with the color inside clipping region
+func (ctx *context) fillMask(mask *image.Alpha, c color.Color) {
+	ctx.paintMask(mask, image.NewUniform(c))
+}
+
+// paintMask paints pixels of the mask with the source image
+// inside clipping region, source is aligned with the image
+func (ctx *context) paintMask(mask *image.Alpha, src image.Image) {
+	if ctx.discard {
+		return
+	}
+	mask = intersectMask(ctx.clipMask(), mask)
+	draw.DrawMask(ctx.img, mask.Bounds(), src, image.Point{},
+		mask, image.Point{}, draw.Over)
+}
+
+// erodeMask returns mask with pixels that have all pixels of the mask
+// within dx horizontally and dy vertically
+func erodeMask(m *image.Alpha, dx, dy int) *image.Alpha {
+	b := m.Bounds()
+	h := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := uint8(0xff)
+			for i := x - dx; i <= x+dx && a != 0; i++ {
+				if i < b.Min.X || i >= b.Max.X {
+					a = 0
+				} else if v := m.AlphaAt(i, y).A; v < a {
+					a = v
+				}
+			}
+			h.SetAlpha(x, y, color.Alpha{a})
+		}
+	}
+
+	v := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := uint8(0xff)
+			for j := y - dy; j <= y+dy && a != 0; j++ {
+				if j < b.Min.Y || j >= b.Max.Y {
+					a = 0
+				} else if v := h.AlphaAt(x, j).A; v < a {
+					a = v
+				}
+			}
+			v.SetAlpha(x, y, color.Alpha{a})
+		}
+	}
+	return v
+}
+
+// subtractMask returns pixels of a mask which are not in b mask
+func subtractMask(a, b *image.Alpha) *image.Alpha {
+	m := image.NewAlpha(a.Bounds())
+	for i := range m.Pix {
+		m.Pix[i] = uint8(uint32(a.Pix[i]) * uint32(0xff-b.Pix[i]) / 0xff)
+	}
+	return m
+}
+
+// imagePoint returns image pixel of the point in logical units
+func (ctx *context) imagePoint(p PointL) (int, int) {
+	x, y := ctx.GetMatrixTransform().TransformPoint(float64(p.X), float64(p.Y))
+	return int(math.Floor(x)), int(math.Floor(y))
+}
+
+// floodMask returns mask of connected pixels of the image starting at x, y.
+// Pixels have the color when surface is true and differ from it otherwise.
+// It returns nil when starting pixel doesn't belong to the area.
+func floodMask(img image.Image, x, y int, c color.RGBA, surface bool) *image.Alpha {
+	b := img.Bounds()
+	inside := func(x, y int) bool {
+		p := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		same := p.R == c.R && p.G == c.G && p.B == c.B
+		return same == surface
+	}
+
+	if !(image.Point{x, y}).In(b) || !inside(x, y) {
+		return nil
+	}
+
+	m := image.NewAlpha(b)
+	stack := []image.Point{{x, y}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if m.AlphaAt(p.X, p.Y).A != 0 {
+			continue
+		}
+
+		// fill the run of the row and queue pixels above and below it
+		x0, x1 := p.X, p.X
+		for x0 > b.Min.X && inside(x0-1, p.Y) {
+			x0--
+		}
+		for x1 < b.Max.X-1 && inside(x1+1, p.Y) {
+			x1++
+		}
+		for x := x0; x <= x1; x++ {
+			m.SetAlpha(x, p.Y, color.Alpha{0xff})
+			for _, y := range []int{p.Y - 1, p.Y + 1} {
+				if y >= b.Min.Y && y < b.Max.Y && m.AlphaAt(x, y).A == 0 && inside(x, y) {
+					stack = append(stack, image.Point{x, y})
+				}
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,218 @@
+package emf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// alphaMask returns mask of the size with pixel values in rows
+func alphaMask(w, h int, pix ...uint8) *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, w, h))
+	copy(m.Pix, pix)
+	return m
+}
+
+func TestErodeMask(t *testing.T) {
+	tests := []struct {
+		name   string
+		mask   *image.Alpha
+		dx, dy int
+		want   []uint8
+	}{
+		{
+			name: "horizontal",
+			mask: alphaMask(5, 1, 0, 0xff, 0xff, 0xff, 0),
+			dx:   1,
+			want: []uint8{0, 0, 0xff, 0, 0},
+		},
+		{
+			name: "pixels outside of mask",
+			mask: alphaMask(3, 1, 0xff, 0xff, 0xff),
+			dx:   1,
+			want: []uint8{0, 0xff, 0},
+		},
+		{
+			name: "vertical",
+			mask: alphaMask(2, 3, 0xff, 0xff, 0xff, 0x80, 0xff, 0xff),
+			dy:   1,
+			want: []uint8{0, 0, 0xff, 0x80, 0, 0},
+		},
+		{
+			name: "no erosion",
+			mask: alphaMask(2, 2, 0xff, 0, 0x80, 0xff),
+			want: []uint8{0xff, 0, 0x80, 0xff},
+		},
+	}
+
+	for _, tt := range tests {
+		got := erodeMask(tt.mask, tt.dx, tt.dy)
+		if !bytes.Equal(got.Pix, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, got.Pix, tt.want)
+		}
+	}
+}
+
+func TestSubtractMask(t *testing.T) {
+	a := alphaMask(4, 1, 0xff, 0xff, 0, 0x80)
+	b := alphaMask(4, 1, 0xff, 0, 0xff, 0x80)
+	want := []uint8{0, 0xff, 0, 0x3f}
+
+	if got := subtractMask(a, b); !bytes.Equal(got.Pix, want) {
+		t.Errorf("got % x, want % x", got.Pix, want)
+	}
+}
+
+func TestCombineMasks(t *testing.T) {
+	a := alphaMask(5, 1, 0xff, 0xff, 0, 0, 0x80)
+	b := alphaMask(5, 1, 0xff, 0, 0xff, 0, 0x80)
+
+	tests := []struct {
+		mode uint32
+		want []uint8
+	}{
+		{RGN_AND, []uint8{0xff, 0, 0, 0, 0x40}},
+		{RGN_OR, []uint8{0xff, 0xff, 0xff, 0, 0xc0}},
+		{RGN_XOR, []uint8{0, 0xff, 0xff, 0, 0x80}},
+		{RGN_DIFF, []uint8{0, 0xff, 0, 0, 0x3f}},
+		{RGN_COPY, []uint8{0xff, 0, 0xff, 0, 0x80}},
+	}
+
+	for _, tt := range tests {
+		if got := combineMasks(a, b, tt.mode); !bytes.Equal(got.Pix, tt.want) {
+			t.Errorf("mode %d: got % x, want % x", tt.mode, got.Pix, tt.want)
+		}
+	}
+}
+
+func TestIntersectMask(t *testing.T) {
+	a := alphaMask(3, 1, 0xff, 0x80, 0)
+	b := alphaMask(3, 1, 0x80, 0xff, 0xff)
+
+	tests := []struct {
+		name string
+		a, b *image.Alpha
+		want *image.Alpha
+	}{
+		{"both masks", a, b, alphaMask(3, 1, 0x80, 0x80, 0)},
+		{"first mask", a, nil, a},
+		{"second mask", nil, b, b},
+		{"no masks", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		got := intersectMask(tt.a, tt.b)
+		if got == nil || tt.want == nil {
+			if got != tt.want {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+			continue
+		}
+		if !bytes.Equal(got.Pix, tt.want.Pix) {
+			t.Errorf("%s: got % x, want % x", tt.name, got.Pix, tt.want.Pix)
+		}
+	}
+}
+
+func TestOffsetMask(t *testing.T) {
+	m := alphaMask(3, 2, 0xff, 0x80, 0, 0, 0, 0)
+
+	tests := []struct {
+		dx, dy int
+		want   []uint8
+	}{
+		{0, 0, []uint8{0xff, 0x80, 0, 0, 0, 0}},
+		{1, 0, []uint8{0, 0xff, 0x80, 0, 0, 0}},
+		{2, 1, []uint8{0, 0, 0, 0, 0, 0xff}},
+		{-1, 0, []uint8{0x80, 0, 0, 0, 0, 0}},
+		{0, 2, []uint8{0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := offsetMask(m, tt.dx, tt.dy); !bytes.Equal(got.Pix, tt.want) {
+			t.Errorf("offset %d, %d: got % x, want % x", tt.dx, tt.dy, got.Pix, tt.want)
+		}
+	}
+}
+
+func TestFullMask(t *testing.T) {
+	m := fullMask(3, 2)
+	if m.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Errorf("got bounds %v", m.Bounds())
+	}
+	if !bytes.Equal(m.Pix, bytes.Repeat([]byte{0xff}, 6)) {
+		t.Errorf("got % x", m.Pix)
+	}
+}
+
+func TestFloodMask(t *testing.T) {
+	white, black := color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0, 0, 0, 0xff}
+	// white areas separated by black border
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for i, c := range []color.RGBA{
+		white, white, black, white,
+		white, white, black, white,
+		black, black, black, white,
+	} {
+		img.SetRGBA(i%4, i/4, c)
+	}
+
+	tests := []struct {
+		name    string
+		x, y    int
+		c       color.RGBA
+		surface bool
+		want    []uint8
+	}{
+		{
+			name:    "surface",
+			c:       white,
+			surface: true,
+			want:    []uint8{0xff, 0xff, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name: "border",
+			x:    3,
+			y:    2,
+			c:    black,
+			want: []uint8{0, 0, 0, 0xff, 0, 0, 0, 0xff, 0, 0, 0, 0xff},
+		},
+		{
+			name:    "border surface",
+			x:       2,
+			c:       black,
+			surface: true,
+			want:    []uint8{0, 0, 0xff, 0, 0, 0, 0xff, 0, 0xff, 0xff, 0xff, 0},
+		},
+		{
+			name: "start on border",
+			x:    2,
+			c:    black,
+		},
+		{
+			name:    "start outside of surface",
+			c:       black,
+			surface: true,
+		},
+		{
+			name:    "start outside of image",
+			x:       4,
+			c:       white,
+			surface: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got := floodMask(img, tt.x, tt.y, tt.c, tt.surface)
+		if got == nil {
+			if tt.want != nil {
+				t.Errorf("%s: got no mask", tt.name)
+			}
+			continue
+		}
+		if !bytes.Equal(got.Pix, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, got.Pix, tt.want)
+		}
+	}
+}
@@ -21,12 +21,14 @@ var errlog = log.New(os.Stderr, "emf: ", 0)
 
 var (
 	flagVersion = flag.Bool("version", false, "")
+	flagMode    = flag.String("mode", "auto", "")
 )
 
 var usage = `EMF images converter
 
 Usage: emftopng [inputfile]
    	--version  print the version number
+   	--mode     records to draw: auto, emf or emfplus (default auto)
 
 `
 
@@ -43,6 +45,20 @@ func main() {
 		os.Exit(0)
 	}
 
+	var mode emf.DrawMode
+	switch *flagMode {
+	case "auto":
+		mode = emf.DrawPlusPreferred
+	case "emf":
+		mode = emf.DrawEmfOnly
+	case "emfplus":
+		mode = emf.DrawPlusOnly
+	default:
+		errlog.Printf("unknown mode %q", *flagMode)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
 	fname := flag.Arg(0)
 
 	var fdata []byte
@@ -68,6 +84,8 @@ func main() {
 		errlog.Fatal(err)
 	}
 
+	file.Mode = mode
+
 	img := file.Draw()
 
 	var f io.Writer
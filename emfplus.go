@@ -0,0 +1,302 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// EMF+ records are stored in EMR_COMMENT records with EMR_COMMENT_EMFPLUS
+// identifier. Objects could be larger than a single record, then their
+// data is split into several object records which could be placed in
+// different comments, they are joined when the file is read.
+
+// PlusRecorder is an EMF+ record
+type PlusRecorder interface {
+	plusRecord() *PlusRecord
+}
+
+// PlusRecord is a common part of EMF+ records
+type PlusRecord struct {
+	Type, Flags    uint16
+	Size, DataSize uint32
+}
+
+func (r *PlusRecord) plusRecord() *PlusRecord { return r }
+
+// readPlusRecords reads EMF+ records from the data of comment record.
+// Records read before an invalid one are returned along with the error,
+// the invalid record is kept with its common part only, so drawing
+// falls back to EMF records when it's a drawing record.
+func readPlusRecords(data []byte) ([]PlusRecorder, error) {
+	var recs []PlusRecorder
+
+	reader := bytes.NewReader(data)
+	for reader.Len() >= 12 {
+		var rec PlusRecord
+		if err := binary.Read(reader, binary.LittleEndian, &rec); err != nil {
+			return recs, err
+		}
+
+		if rec.Size < 12 || rec.DataSize > rec.Size-12 || int(rec.Size-12) > reader.Len() {
+			recs = append(recs, &rec)
+			return recs, fmt.Errorf("invalid EMF+ record %#v size %d", rec.Type, rec.Size)
+		}
+
+		// record data is read from its own reader,
+		// size of the record could include padding
+		buf := make([]byte, rec.Size-12)
+		reader.Read(buf)
+		recReader := bytes.NewReader(buf[:rec.DataSize])
+
+		fn := plusRecords[rec.Type]
+		if fn == nil {
+			// default implementation skips record data
+			r := rec
+			recs = append(recs, &r)
+			continue
+		}
+
+		r, err := fn(recReader, rec)
+		if err != nil {
+			recs = append(recs, &rec)
+			return recs, fmt.Errorf("invalid EMF+ record %#v: %v", rec.Type, err)
+		}
+		recs = append(recs, r)
+	}
+
+	return recs, nil
+}
+
+// plusObjects joins continued object records of EMF+ records
+type plusObjects struct {
+	// object with data split into several records
+	continued *PlusObjectRecord
+}
+
+// join returns records with chunks of continued objects replaced
+// by single object record placed instead of the last chunk
+func (p *plusObjects) join(recs []PlusRecorder) []PlusRecorder {
+	var joined []PlusRecorder
+
+	for _, rec := range recs {
+		obj, ok := rec.(*PlusObjectRecord)
+		if !ok {
+			joined = append(joined, rec)
+			continue
+		}
+
+		if c := p.continued; c != nil && c.ObjectID == obj.ObjectID && c.ObjectType == obj.ObjectType {
+			c.Data = append(c.Data, obj.Data...)
+			if uint32(len(c.Data)) < c.TotalObjectSize {
+				continue
+			}
+			p.continued = nil
+			obj = c
+		} else if obj.Flags&PLUSFLAG_CONTINUE != 0 && uint32(len(obj.Data)) < obj.TotalObjectSize {
+			c := *obj
+			c.Data = append([]byte(nil), obj.Data...)
+			p.continued = &c
+			continue
+		}
+
+		obj.parse()
+		joined = append(joined, obj)
+	}
+
+	return joined
+}
+
+type PlusHeaderRecord struct {
+	PlusRecord
+	Version                  uint32
+	EmfPlusFlags             uint32
+	LogicalDpiX, LogicalDpiY uint32
+}
+
+func readPlusHeaderRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusHeaderRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.EmfPlusFlags); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.LogicalDpiX); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.LogicalDpiY); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusHeaderRecord) Draw(ctx *context) {}
+
+// Dual reports whether metafile contains EMF records
+// which render the same picture as EMF+ records
+func (r *PlusHeaderRecord) Dual() bool {
+	return r.Flags&PLUSFLAG_DUAL != 0
+}
+
+type PlusObjectRecord struct {
+	PlusRecord
+	ObjectID, ObjectType uint8
+	// size of the object data of continued object
+	TotalObjectSize uint32
+	Data            []byte
+	// parsed object, it's nil for unsupported object types
+	// and chunks of continued objects
+	Object interface{}
+}
+
+func readPlusObjectRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusObjectRecord{PlusRecord: rec}
+	r.ObjectID = uint8(rec.Flags)
+	r.ObjectType = uint8(rec.Flags>>8) & 0x7f
+
+	if rec.Flags&PLUSFLAG_CONTINUE != 0 {
+		if err := binary.Read(reader, binary.LittleEndian, &r.TotalObjectSize); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Data = make([]byte, reader.Len())
+	reader.Read(r.Data)
+	return r, nil
+}
+
+func (r *PlusObjectRecord) Draw(ctx *context) {
+	ctx.plus.objects[r.ObjectID] = r.Object
+}
+
+// parse parses object data
+func (r *PlusObjectRecord) parse() {
+	fn, ok := plusObjectReaders[r.ObjectType]
+	if !ok {
+		return
+	}
+
+	object, err := fn(bytes.NewReader(r.Data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emf: unable to parse EMF+ object %d: %v\n", r.ObjectID, err)
+		return
+	}
+	r.Object = object
+}
+
+// PlusARGB is a color with alpha channel
+type PlusARGB struct {
+	Blue, Green, Red, Alpha uint8
+}
+
+func (c PlusARGB) GetColor() color.NRGBA {
+	return color.NRGBA{c.Red, c.Green, c.Blue, c.Alpha}
+}
+
+type PlusPointF struct {
+	X, Y float32
+}
+
+type PlusRectF struct {
+	X, Y, Width, Height float32
+}
+
+// readPlusPoints reads points stored as floats, 16-bit integers
+// or relative to the previous point depending on flags
+func readPlusPoints(reader *bytes.Reader, count uint32, flags uint16) ([]PlusPointF, error) {
+	if int(count) > reader.Len() {
+		return nil, fmt.Errorf("invalid number of points %d", count)
+	}
+
+	points := make([]PlusPointF, count)
+	switch {
+	case flags&PLUSFLAG_RELATIVE != 0:
+		var x, y float32
+		for i := range points {
+			dx, err := readPlusInteger(reader)
+			if err != nil {
+				return nil, err
+			}
+			dy, err := readPlusInteger(reader)
+			if err != nil {
+				return nil, err
+			}
+			x, y = x+float32(dx), y+float32(dy)
+			points[i] = PlusPointF{x, y}
+		}
+	case flags&PLUSFLAG_COMPRESSED != 0:
+		s := make([]PointS, count)
+		if err := binary.Read(reader, binary.LittleEndian, &s); err != nil {
+			return nil, err
+		}
+		for i, p := range s {
+			points[i] = PlusPointF{float32(p.X), float32(p.Y)}
+		}
+	default:
+		if err := binary.Read(reader, binary.LittleEndian, &points); err != nil {
+			return nil, err
+		}
+	}
+
+	return points, nil
+}
+
+// readPlusInteger reads 7-bit or 15-bit signed integer
+// of relative point coordinates
+func readPlusInteger(reader *bytes.Reader) (int32, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if b&0x80 == 0 {
+		return int32(int8(b<<1) >> 1), nil
+	}
+
+	b1, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return int32(int16((uint16(b)<<8|uint16(b1))<<1) >> 1), nil
+}
+
+// readPlusRects reads rectangles stored as floats or 16-bit integers
+func readPlusRects(reader *bytes.Reader, count uint32, flags uint16) ([]PlusRectF, error) {
+	if int(count) > reader.Len() {
+		return nil, fmt.Errorf("invalid number of rectangles %d", count)
+	}
+
+	rects := make([]PlusRectF, count)
+	if flags&PLUSFLAG_COMPRESSED == 0 {
+		if err := binary.Read(reader, binary.LittleEndian, &rects); err != nil {
+			return nil, err
+		}
+		return rects, nil
+	}
+
+	s := make([]struct{ X, Y, Width, Height int16 }, count)
+	if err := binary.Read(reader, binary.LittleEndian, &s); err != nil {
+		return nil, err
+	}
+	for i, rc := range s {
+		rects[i] = PlusRectF{float32(rc.X), float32(rc.Y), float32(rc.Width), float32(rc.Height)}
+	}
+	return rects, nil
+}
+
+// readPlusRect reads a single rectangle
+func readPlusRect(reader *bytes.Reader, flags uint16) (PlusRectF, error) {
+	rects, err := readPlusRects(reader, 1, flags)
+	if err != nil {
+		return PlusRectF{}, err
+	}
+	return rects[0], nil
+}
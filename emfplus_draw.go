@@ -0,0 +1,810 @@
+package emf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"math"
+	"os"
+
+	"github.com/llgcode/draw2d"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// EMF+ records are drawn with their own graphics state: world and page
+// transformations, clipping region and object table are independent of
+// the device context of EMF records. In dual files EMF records which are
+// not preceded by GetDC record duplicate EMF+ drawing, they are played
+// without painting to keep device context up to date and drawn only when
+// EMF+ records of the preceding comment are not supported.
+
+// plusDrawer is implemented by EMF+ records supported by renderer
+type plusDrawer interface {
+	Draw(*context)
+}
+
+// drawing reports whether record draws on the image
+func (r *PlusRecord) drawing() bool {
+	return r.Type >= EMFPLUS_CLEAR && r.Type <= EMFPLUS_DRAWSTRING ||
+		r.Type == EMFPLUS_DRAWDRIVERSTRING || r.Type == EMFPLUS_STROKEFILLPATH
+}
+
+// plusState is a graphics state saved by Save and BeginContainer records
+type plusState struct {
+	world     draw2d.Matrix
+	pageUnit  uint32
+	pageScale float64
+	// clipping mask in image space, nil when there is no clipping
+	clip *image.Alpha
+}
+
+type plusContext struct {
+	plusState
+	dpiX, dpiY float64
+	objects    map[uint8]interface{}
+	saved      map[uint32]plusState
+}
+
+func newPlusContext(h *PlusHeaderRecord) *plusContext {
+	p := &plusContext{
+		plusState: plusState{
+			world:     draw2d.NewIdentityMatrix(),
+			pageUnit:  UNITTYPE_PIXEL,
+			pageScale: 1,
+		},
+		dpiX:    float64(h.LogicalDpiX),
+		dpiY:    float64(h.LogicalDpiY),
+		objects: make(map[uint8]interface{}),
+		saved:   make(map[uint32]plusState),
+	}
+	if p.dpiX == 0 || p.dpiY == 0 {
+		p.dpiX, p.dpiY = 96, 96
+	}
+	return p
+}
+
+// multiply multiplies world transformation by the matrix,
+// it's applied after world transformation when PLUSFLAG_APPEND is set
+func (p *plusContext) multiply(m draw2d.Matrix, flags uint16) {
+	if flags&PLUSFLAG_APPEND != 0 {
+		m.Compose(p.world)
+		p.world = m
+	} else {
+		p.world.Compose(m)
+	}
+}
+
+// restore restores graphics state saved with the stack index
+func (p *plusContext) restore(idx uint32) {
+	state, ok := p.saved[idx]
+	if !ok {
+		return
+	}
+	p.plusState = state
+	delete(p.saved, idx)
+}
+
+// unitScale returns number of device pixels in the unit
+func unitScale(unit uint32, dpi float64) float64 {
+	switch unit {
+	case UNITTYPE_POINT:
+		return dpi / 72
+	case UNITTYPE_INCH:
+		return dpi
+	case UNITTYPE_DOCUMENT:
+		return dpi / 300
+	case UNITTYPE_MILLIMETER:
+		return dpi / 25.4
+	default:
+		return 1
+	}
+}
+
+// plusMatrix returns matrix from EMF+ transformation
+func plusMatrix(m [6]float32) draw2d.Matrix {
+	return draw2d.Matrix{
+		float64(m[0]), float64(m[1]),
+		float64(m[2]), float64(m[3]),
+		float64(m[4]), float64(m[5]),
+	}
+}
+
+// plusTransform returns transformation from world units to image space
+func (ctx *context) plusTransform() draw2d.Matrix {
+	p := ctx.plus
+	tr := ctx.device
+	tr.Compose(draw2d.NewScaleMatrix(
+		p.pageScale*unitScale(p.pageUnit, p.dpiX),
+		p.pageScale*unitScale(p.pageUnit, p.dpiY)))
+	tr.Compose(p.world)
+	return tr
+}
+
+// plusBegin sets up graphic context for drawing of EMF+ record,
+// state of EMF drawing is restored by plusEnd
+func (ctx *context) plusBegin() {
+	ctx.Save()
+	ctx.SetMatrixTransform(ctx.plusTransform())
+	ctx.BeginPath()
+	ctx.painter.mask = ctx.plus.clip
+}
+
+func (ctx *context) plusEnd() {
+	ctx.Restore()
+	ctx.updateClip()
+}
+
+// setDiscard turns painting of EMF records off or on
+func (ctx *context) setDiscard(discard bool) {
+	ctx.discard = discard
+	ctx.painter.discard = discard
+}
+
+// drawPlus draws EMF+ records of the file, EMF records are drawn
+// when they follow GetDC record or replace unsupported EMF+ records
+func (f *EmfFile) drawPlus(ctx *context) {
+	ctx.plus = newPlusContext(f.PlusHeader)
+
+	ctx.setDiscard(true)
+
+	for _, rec := range f.Records {
+		c, ok := rec.(*CommentRecord)
+		if !ok || c.PlusRecords == nil {
+			rec.Draw(ctx)
+			continue
+		}
+
+		ctx.setDiscard(false)
+		if !ctx.drawPlusRecords(c.PlusRecords, f.Mode == DrawPlusPreferred) {
+			ctx.setDiscard(true)
+		}
+	}
+
+	ctx.setDiscard(false)
+}
+
+// drawPlusRecords draws EMF+ records of a comment and reports whether
+// following EMF records should be drawn. With fallback drawing records
+// of the comment are skipped when any of them is not supported.
+func (ctx *context) drawPlusRecords(recs []PlusRecorder, fallback bool) bool {
+	skip := fallback && !ctx.plusSupported(recs)
+
+	gdi := skip
+	for _, rec := range recs {
+		if _, ok := rec.(*PlusGetdcRecord); ok {
+			gdi = true
+			continue
+		}
+
+		d, ok := rec.(plusDrawer)
+		if !ok || skip && rec.plusRecord().drawing() {
+			continue
+		}
+		d.Draw(ctx)
+	}
+
+	return gdi
+}
+
+// plusSupported reports whether all drawing records of a comment can be
+// drawn, records have to be implemented and the brushes, pens and images
+// they use have to be supported. Objects are looked up as they are defined
+// at the time of drawing of each record.
+func (ctx *context) plusSupported(recs []PlusRecorder) bool {
+	objects := make(map[uint8]interface{})
+	object := func(id uint8) interface{} {
+		if o, ok := objects[id]; ok {
+			return o
+		}
+		return ctx.plus.objects[id]
+	}
+
+	for _, rec := range recs {
+		if o, ok := rec.(*PlusObjectRecord); ok {
+			objects[o.ObjectID] = o.Object
+			continue
+		}
+
+		if !rec.plusRecord().drawing() {
+			continue
+		}
+		if _, ok := rec.(plusDrawer); !ok {
+			return false
+		}
+
+		switch r := rec.(type) {
+		case interface{ brush() (uint16, uint32) }:
+			flags, id := r.brush()
+			if flags&PLUSFLAG_SOLIDCOLOR != 0 {
+				continue
+			}
+			if b, ok := object(uint8(id)).(PlusBrush); !ok || !b.supported() {
+				return false
+			}
+		case *PlusDrawrectsRecord, *PlusDrawlinesRecord, *PlusDrawbeziersRecord,
+			*PlusDrawellipseRecord, *PlusDrawpieRecord, *PlusDrawarcRecord,
+			*PlusDrawclosedcurveRecord, *PlusDrawcurveRecord, *PlusDrawpathRecord:
+			id := rec.plusRecord().objectID()
+			if r, ok := rec.(*PlusDrawpathRecord); ok {
+				id = uint8(r.PenID)
+			}
+			if p, ok := object(id).(PlusPen); !ok || !p.Brush.supported() {
+				return false
+			}
+		case *PlusDrawimageRecord, *PlusDrawimagepointsRecord:
+			if img, ok := object(rec.plusRecord().objectID()).(PlusImage); !ok || img.check() != nil {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// plusColor returns color stored in brush index of records
+// with PLUSFLAG_SOLIDCOLOR flag
+func plusColor(v uint32) PlusARGB {
+	return PlusARGB{uint8(v), uint8(v >> 8), uint8(v >> 16), uint8(v >> 24)}
+}
+
+// plusBrush returns image painted by brush specified in the record,
+// it's nil when brush is not found or not supported
+func (ctx *context) plusBrush(flags uint16, id uint32) image.Image {
+	if flags&PLUSFLAG_SOLIDCOLOR != 0 {
+		return image.NewUniform(plusColor(id).GetColor())
+	}
+
+	brush, ok := ctx.plus.objects[uint8(id)].(PlusBrush)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ brush %d not found\n", id)
+		return nil
+	}
+
+	switch brush.Type {
+	case BRUSHTYPE_SOLIDCOLOR:
+		return image.NewUniform(brush.Color.GetColor())
+	case BRUSHTYPE_HATCHFILL:
+		return &hatchImage{
+			style: brush.HatchStyle,
+			fore:  brush.Color.GetColor(),
+			back:  brush.BackColor.GetColor(),
+		}
+	case BRUSHTYPE_LINEARGRADIENT:
+		// image pixels are mapped back to world units
+		tr := ctx.plusTransform()
+		tr.Inverse()
+		return &gradientImage{
+			tr:    tr,
+			rect:  brush.Rect,
+			start: brush.Color.GetColor(),
+			end:   brush.BackColor.GetColor(),
+			wrap:  brush.WrapMode,
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "emf: EMF+ brush type %d is not supported\n", brush.Type)
+	return nil
+}
+
+// supported reports whether the brush is painted by plusBrush,
+// pens are drawn with the main color of such brushes
+func (b PlusBrush) supported() bool {
+	switch b.Type {
+	case BRUSHTYPE_SOLIDCOLOR, BRUSHTYPE_HATCHFILL, BRUSHTYPE_LINEARGRADIENT:
+		return true
+	}
+	return false
+}
+
+// hatchImage is an infinite pattern of hatch brush
+type hatchImage struct {
+	style      uint32
+	fore, back color.NRGBA
+}
+
+func (h *hatchImage) ColorModel() color.Model { return color.NRGBAModel }
+
+func (h *hatchImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (h *hatchImage) At(x, y int) color.Color {
+	// hatch lines are repeated every 8 pixels
+	x, y = x&7, y&7
+
+	var on bool
+	switch h.style {
+	case HATCHSTYLE_HORIZONTAL:
+		on = y == 0
+	case HATCHSTYLE_VERTICAL:
+		on = x == 0
+	case HATCHSTYLE_FORWARDDIAGONAL:
+		on = x == y
+	case HATCHSTYLE_BACKWARDDIAGONAL:
+		on = x+y == 7
+	case HATCHSTYLE_LARGEGRID:
+		on = x == 0 || y == 0
+	case HATCHSTYLE_DIAGONALCROSS:
+		on = x == y || x+y == 7
+	default:
+		// other patterns are approximated with a checkerboard
+		on = (x+y)&1 == 0
+	}
+
+	if on {
+		return h.fore
+	}
+	return h.back
+}
+
+// gradientImage is linear gradient brush going horizontally
+// through the rectangle in world units
+type gradientImage struct {
+	// transformation from image space to world units
+	tr         draw2d.Matrix
+	rect       PlusRectF
+	start, end color.NRGBA
+	wrap       int32
+}
+
+func (g *gradientImage) ColorModel() color.Model { return color.NRGBAModel }
+
+func (g *gradientImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (g *gradientImage) At(x, y int) color.Color {
+	if g.rect.Width == 0 {
+		return g.start
+	}
+
+	wx, _ := g.tr.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+	t := (wx - float64(g.rect.X)) / float64(g.rect.Width)
+
+	switch g.wrap {
+	case WRAPMODE_CLAMP:
+		t = math.Max(0, math.Min(1, t))
+	case WRAPMODE_TILEFLIPX, WRAPMODE_TILEFLIPXY:
+		t = math.Mod(math.Abs(t), 2)
+		if t > 1 {
+			t = 2 - t
+		}
+	default:
+		t -= math.Floor(t)
+	}
+
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.NRGBA{
+		lerp(g.start.R, g.end.R), lerp(g.start.G, g.end.G),
+		lerp(g.start.B, g.end.B), lerp(g.start.A, g.end.A),
+	}
+}
+
+// plusFill fills paths with the brush of the record
+func (ctx *context) plusFill(flags uint16, brushID uint32, fillRule draw2d.FillRule, paths ...*draw2d.Path) {
+	ctx.plusBegin()
+	defer ctx.plusEnd()
+
+	src := ctx.plusBrush(flags, brushID)
+	if src == nil {
+		return
+	}
+	ctx.paintMask(ctx.pathMask(fillRule, paths...), src)
+}
+
+// dash patterns of line styles in pen widths
+var plusDashes = map[int32][]float64{
+	LINESTYLE_DASH:       {3, 1},
+	LINESTYLE_DOT:        {1, 1},
+	LINESTYLE_DASHDOT:    {3, 1, 1, 1},
+	LINESTYLE_DASHDOTDOT: {3, 1, 1, 1, 1, 1},
+}
+
+// plusStroke strokes paths with the pen object
+func (ctx *context) plusStroke(penID uint8, paths ...*draw2d.Path) {
+	pen, ok := ctx.plus.objects[penID].(PlusPen)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ pen %d not found\n", penID)
+		return
+	}
+
+	ctx.plusBegin()
+	defer ctx.plusEnd()
+
+	// pen of other brush types is drawn with its main color
+	ctx.SetStrokeColor(pen.Brush.Color.GetColor())
+
+	scale := ctx.GetMatrixTransform().GetScale()
+	width := float64(pen.PenWidth)
+	if pen.PenUnit != UNITTYPE_WORLD {
+		width = width * unitScale(pen.PenUnit, ctx.plus.dpiX) / scale
+	}
+	if width*scale < 1 {
+		// pens are at least one pixel wide
+		width = 1 / scale
+	}
+	ctx.SetLineWidth(width)
+
+	switch pen.StartCap {
+	case LINECAPTYPE_SQUARE:
+		ctx.SetLineCap(draw2d.SquareCap)
+	case LINECAPTYPE_ROUND:
+		ctx.SetLineCap(draw2d.RoundCap)
+	default:
+		ctx.SetLineCap(draw2d.ButtCap)
+	}
+
+	switch pen.Join {
+	case LINEJOINTYPE_BEVEL:
+		ctx.SetLineJoin(draw2d.BevelJoin)
+	case LINEJOINTYPE_ROUND:
+		ctx.SetLineJoin(draw2d.RoundJoin)
+	default:
+		ctx.SetLineJoin(draw2d.MiterJoin)
+	}
+
+	dash := plusDashes[pen.LineStyle]
+	if pen.LineStyle == LINESTYLE_CUSTOM {
+		dash = make([]float64, len(pen.DashedLineData))
+		for i, d := range pen.DashedLineData {
+			dash[i] = float64(d)
+		}
+	}
+	if dash != nil {
+		d := make([]float64, len(dash))
+		for i := range dash {
+			d[i] = dash[i] * width
+		}
+		ctx.SetLineDash(d, float64(pen.DashOffset)*width)
+	} else {
+		ctx.SetLineDash(nil, 0)
+	}
+
+	ctx.Stroke(paths...)
+}
+
+// plusPathMask returns mask of paths in world units
+func (ctx *context) plusPathMask(fillRule draw2d.FillRule, paths ...*draw2d.Path) *image.Alpha {
+	ctx.Save()
+	defer ctx.Restore()
+	ctx.SetMatrixTransform(ctx.plusTransform())
+	return ctx.pathMask(fillRule, paths...)
+}
+
+// plusRegionMask returns mask of the region node
+func (ctx *context) plusRegionMask(node *PlusRegionNode) *image.Alpha {
+	switch node.Type {
+	case REGIONNODEDATATYPE_RECT:
+		return ctx.plusPathMask(draw2d.FillRuleWinding, plusRectsPath([]PlusRectF{node.Rect}))
+	case REGIONNODEDATATYPE_PATH:
+		return ctx.plusPathMask(draw2d.FillRuleEvenOdd, node.Path.path())
+	case REGIONNODEDATATYPE_INFINITE:
+		return fullMask(ctx.w, ctx.h)
+	case REGIONNODEDATATYPE_EMPTY:
+		return image.NewAlpha(image.Rect(0, 0, ctx.w, ctx.h))
+	}
+
+	left, right := ctx.plusRegionMask(node.Left), ctx.plusRegionMask(node.Right)
+	switch node.Type {
+	case REGIONNODEDATATYPE_AND:
+		return combineMasks(left, right, RGN_AND)
+	case REGIONNODEDATATYPE_OR:
+		return combineMasks(left, right, RGN_OR)
+	case REGIONNODEDATATYPE_XOR:
+		return combineMasks(left, right, RGN_XOR)
+	case REGIONNODEDATATYPE_EXCLUDE:
+		return combineMasks(left, right, RGN_DIFF)
+	default:
+		return combineMasks(right, left, RGN_DIFF)
+	}
+}
+
+// combinePlusClip combines clipping region with the mask
+// using COMBINEMODE_* mode
+func (ctx *context) combinePlusClip(mask *image.Alpha, mode uint8) {
+	if mode == COMBINEMODE_REPLACE {
+		ctx.plus.clip = mask
+		return
+	}
+
+	clip := ctx.plus.clip
+	if clip == nil {
+		clip = fullMask(ctx.w, ctx.h)
+	}
+
+	switch mode {
+	case COMBINEMODE_INTERSECT:
+		clip = combineMasks(clip, mask, RGN_AND)
+	case COMBINEMODE_UNION:
+		clip = combineMasks(clip, mask, RGN_OR)
+	case COMBINEMODE_XOR:
+		clip = combineMasks(clip, mask, RGN_XOR)
+	case COMBINEMODE_EXCLUDE:
+		clip = combineMasks(clip, mask, RGN_DIFF)
+	case COMBINEMODE_COMPLEMENT:
+		clip = combineMasks(mask, clip, RGN_DIFF)
+	}
+	ctx.plus.clip = clip
+}
+
+// plusRectsPath returns path of rectangles
+func plusRectsPath(rects []PlusRectF) *draw2d.Path {
+	p := &draw2d.Path{}
+	for _, rc := range rects {
+		x1, y1 := float64(rc.X), float64(rc.Y)
+		x2, y2 := x1+float64(rc.Width), y1+float64(rc.Height)
+		p.MoveTo(x1, y1)
+		p.LineTo(x2, y1)
+		p.LineTo(x2, y2)
+		p.LineTo(x1, y2)
+		p.Close()
+	}
+	return p
+}
+
+// plusLinesPath returns path of connected lines
+func plusLinesPath(points []PlusPointF, closed bool) *draw2d.Path {
+	p := &draw2d.Path{}
+	for i, pt := range points {
+		if i == 0 {
+			p.MoveTo(float64(pt.X), float64(pt.Y))
+		} else {
+			p.LineTo(float64(pt.X), float64(pt.Y))
+		}
+	}
+	if closed && len(points) > 0 {
+		p.Close()
+	}
+	return p
+}
+
+// plusBeziersPath returns path of connected Bezier curves,
+// each curve after the first point is defined by three points
+func plusBeziersPath(points []PlusPointF) *draw2d.Path {
+	p := &draw2d.Path{}
+	if len(points) == 0 {
+		return p
+	}
+
+	p.MoveTo(float64(points[0].X), float64(points[0].Y))
+	for i := 1; i+2 < len(points); i += 3 {
+		p.CubicCurveTo(
+			float64(points[i].X), float64(points[i].Y),
+			float64(points[i+1].X), float64(points[i+1].Y),
+			float64(points[i+2].X), float64(points[i+2].Y))
+	}
+	return p
+}
+
+// plusEllipsePath returns path of the ellipse bounded by rectangle
+func plusEllipsePath(rc PlusRectF) *draw2d.Path {
+	p := &draw2d.Path{}
+	rx, ry := float64(rc.Width)/2, float64(rc.Height)/2
+	p.ArcTo(float64(rc.X)+rx, float64(rc.Y)+ry, rx, ry, 0, 2*math.Pi)
+	p.Close()
+	return p
+}
+
+// ellipseAngle returns parametric angle of the ellipse point
+// at the angle in degrees
+func ellipseAngle(a float64, rx, ry float64) float64 {
+	a = a * math.Pi / 180
+	return math.Atan2(rx*math.Sin(a), ry*math.Cos(a))
+}
+
+// path returns path of the arc, pie is closed through the center
+func (a plusArc) path(pie bool) *draw2d.Path {
+	p := &draw2d.Path{}
+	rx, ry := float64(a.Rect.Width)/2, float64(a.Rect.Height)/2
+	cx, cy := float64(a.Rect.X)+rx, float64(a.Rect.Y)+ry
+
+	start, sweep := float64(a.StartAngle), float64(a.SweepAngle)
+	t0 := ellipseAngle(start, rx, ry)
+	var angle float64
+	if math.Abs(sweep) >= 360 {
+		angle = math.Copysign(2*math.Pi, sweep)
+	} else {
+		angle = ellipseAngle(start+sweep, rx, ry) - t0
+		if sweep > 0 && angle < 0 {
+			angle += 2 * math.Pi
+		} else if sweep < 0 && angle > 0 {
+			angle -= 2 * math.Pi
+		}
+	}
+
+	if pie {
+		p.MoveTo(cx, cy)
+	}
+	p.ArcTo(cx, cy, rx, ry, t0, angle)
+	if pie {
+		p.Close()
+	}
+	return p
+}
+
+// tension of cardinal splines scaled for Bezier control points
+const curveTension = 0.3
+
+// plusCurvePath returns path of cardinal spline through the points
+// drawing segments starting at offset
+func plusCurvePath(points []PlusPointF, tension float32, offset, segments int, closed bool) *draw2d.Path {
+	p := &draw2d.Path{}
+	n := len(points)
+	if n < 2 {
+		return p
+	}
+
+	pt := func(i int) (float64, float64) {
+		if closed {
+			i = (i%n + n) % n
+		} else if i < 0 {
+			i = 0
+		} else if i >= n {
+			i = n - 1
+		}
+		return float64(points[i].X), float64(points[i].Y)
+	}
+
+	t := float64(tension) * curveTension
+	if closed {
+		offset, segments = 0, n
+	}
+
+	x, y := pt(offset)
+	p.MoveTo(x, y)
+	for i := offset; i < offset+segments && (closed || i+1 < n); i++ {
+		x0, y0 := pt(i - 1)
+		x1, y1 := pt(i)
+		x2, y2 := pt(i + 1)
+		x3, y3 := pt(i + 2)
+		p.CubicCurveTo(
+			x1+t*(x2-x0), y1+t*(y2-y0),
+			x2-t*(x3-x1), y2-t*(y3-y1),
+			x2, y2)
+	}
+	if closed {
+		p.Close()
+	}
+	return p
+}
+
+// path returns path of the path object
+func (r *PlusPath) path() *draw2d.Path {
+	p := &draw2d.Path{}
+	for i := 0; i < len(r.Points) && i < len(r.Types); i++ {
+		x, y := float64(r.Points[i].X), float64(r.Points[i].Y)
+		t := r.Types[i]
+
+		switch t & 0x0f {
+		case PATHPOINTTYPE_START:
+			p.MoveTo(x, y)
+		case PATHPOINTTYPE_LINE:
+			p.LineTo(x, y)
+		case PATHPOINTTYPE_BEZIER:
+			if i+2 >= len(r.Points) || i+2 >= len(r.Types) {
+				return p
+			}
+			p.CubicCurveTo(x, y,
+				float64(r.Points[i+1].X), float64(r.Points[i+1].Y),
+				float64(r.Points[i+2].X), float64(r.Points[i+2].Y))
+			i += 2
+			t = r.Types[i]
+		}
+
+		if t&PATHPOINTTYPE_CLOSESUBPATH != 0 {
+			p.Close()
+		}
+	}
+	return p
+}
+
+// check returns an error when the image can't be decoded
+// because of its type, pixel format or compression
+func (r *PlusImage) check() error {
+	if r.Type != IMAGEDATATYPE_BITMAP {
+		return fmt.Errorf("metafile images are not supported")
+	}
+
+	if r.BitmapDataType != 0 {
+		_, _, err := image.DecodeConfig(bytes.NewReader(r.Data))
+		return err
+	}
+
+	switch r.PixelFormat {
+	case PIXELFORMAT_24BPPRGB, PIXELFORMAT_32BPPRGB, PIXELFORMAT_32BPPARGB, PIXELFORMAT_32BPPPARGB:
+		return nil
+	}
+	return fmt.Errorf("pixel format %#x is not supported", r.PixelFormat)
+}
+
+func (r *PlusImage) image() (image.Image, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	if r.BitmapDataType != 0 {
+		img, _, err := image.Decode(bytes.NewReader(r.Data))
+		return img, err
+	}
+
+	bpp := 4
+	if r.PixelFormat == PIXELFORMAT_24BPPRGB {
+		bpp = 3
+	}
+
+	w, h, stride := int(r.Width), int(r.Height), int(r.Stride)
+	if w <= 0 || h <= 0 || stride < w*bpp || len(r.Data) < stride*(h-1)+w*bpp {
+		return nil, fmt.Errorf("invalid bitmap size %dx%d", w, h)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s := r.Data[y*stride+x*bpp:]
+			c := color.NRGBA{s[2], s[1], s[0], 0xff}
+			switch r.PixelFormat {
+			case PIXELFORMAT_32BPPARGB:
+				c.A = s[3]
+			case PIXELFORMAT_32BPPPARGB:
+				c.A = s[3]
+				if c.A != 0 && c.A != 0xff {
+					c.R = uint8(uint32(c.R) * 0xff / uint32(c.A))
+					c.G = uint8(uint32(c.G) * 0xff / uint32(c.A))
+					c.B = uint8(uint32(c.B) * 0xff / uint32(c.A))
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// plusDrawImage draws source rectangle of the image object into
+// parallelogram defined by its upper-left, upper-right and
+// lower-left corners
+func (ctx *context) plusDrawImage(id uint8, src PlusRectF, points []PlusPointF) {
+	object, ok := ctx.plus.objects[id].(PlusImage)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ image %d not found\n", id)
+		return
+	}
+
+	img, err := object.image()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emf: unable to draw EMF+ image %d: %v\n", id, err)
+		return
+	}
+
+	sr := image.Rect(
+		int(math.Round(float64(src.X))), int(math.Round(float64(src.Y))),
+		int(math.Round(float64(src.X+src.Width))), int(math.Round(float64(src.Y+src.Height))),
+	).Intersect(img.Bounds())
+	if sr.Empty() || src.Width == 0 || src.Height == 0 {
+		return
+	}
+
+	// source pixels to world units
+	p0, p1, p2 := points[0], points[1], points[2]
+	m := draw2d.Matrix{
+		float64(p1.X-p0.X) / float64(src.Width), float64(p1.Y-p0.Y) / float64(src.Width),
+		float64(p2.X-p0.X) / float64(src.Height), float64(p2.Y-p0.Y) / float64(src.Height),
+		float64(p0.X), float64(p0.Y),
+	}
+	m.Compose(draw2d.NewTranslationMatrix(-float64(src.X), -float64(src.Y)))
+	// and then to image space
+	tr := ctx.plusTransform()
+	tr.Compose(m)
+
+	var opts *draw.Options
+	if ctx.plus.clip != nil {
+		opts = &draw.Options{DstMask: ctx.plus.clip}
+	}
+
+	draw.BiLinear.Transform(ctx.img, f64.Aff3{tr[0], tr[2], tr[4], tr[1], tr[3], tr[5]},
+		img, sr, draw.Over, opts)
+}
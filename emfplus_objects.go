@@ -0,0 +1,444 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// readers of EMF+ objects by object type
+var plusObjectReaders = map[uint8]func(*bytes.Reader) (interface{}, error){
+	OBJECTTYPE_BRUSH:  func(r *bytes.Reader) (interface{}, error) { return readPlusBrush(r) },
+	OBJECTTYPE_PEN:    func(r *bytes.Reader) (interface{}, error) { return readPlusPen(r) },
+	OBJECTTYPE_PATH:   func(r *bytes.Reader) (interface{}, error) { return readPlusPath(r) },
+	OBJECTTYPE_REGION: func(r *bytes.Reader) (interface{}, error) { return readPlusRegion(r) },
+	OBJECTTYPE_IMAGE:  func(r *bytes.Reader) (interface{}, error) { return readPlusImage(r) },
+	OBJECTTYPE_FONT:   func(r *bytes.Reader) (interface{}, error) { return readPlusFont(r) },
+}
+
+// PlusBrush is EMF+ brush object, only solid, hatch and linear
+// gradient brushes have their data parsed
+type PlusBrush struct {
+	Version, Type uint32
+	// color of solid brush, foreground color of hatch brush
+	// or start color of linear gradient brush
+	Color PlusARGB
+	// background color of hatch brush or end color of linear gradient brush
+	BackColor PlusARGB
+	// hatch style of hatch brush
+	HatchStyle uint32
+	// flags, wrap mode and rectangle of linear gradient brush
+	BrushDataFlags uint32
+	WrapMode       int32
+	Rect           PlusRectF
+	// brush data of other brush types
+	Data []byte
+}
+
+func readPlusBrush(reader *bytes.Reader) (PlusBrush, error) {
+	r := PlusBrush{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Type); err != nil {
+		return r, err
+	}
+
+	switch r.Type {
+	case BRUSHTYPE_SOLIDCOLOR:
+		if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+			return r, err
+		}
+	case BRUSHTYPE_HATCHFILL:
+		if err := binary.Read(reader, binary.LittleEndian, &r.HatchStyle); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.BackColor); err != nil {
+			return r, err
+		}
+	case BRUSHTYPE_LINEARGRADIENT:
+		if err := binary.Read(reader, binary.LittleEndian, &r.BrushDataFlags); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.WrapMode); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Rect); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.BackColor); err != nil {
+			return r, err
+		}
+		// Reserved1, Reserved2 and optional data
+		r.Data = readRest(reader)
+	default:
+		r.Data = readRest(reader)
+	}
+
+	return r, nil
+}
+
+// PlusPen is EMF+ pen object
+type PlusPen struct {
+	Version, Type uint32
+	PenDataFlags  uint32
+	PenUnit       uint32
+	PenWidth      float32
+
+	// optional data present according to PenDataFlags
+	Transform                    [6]float32
+	StartCap, EndCap             int32
+	Join                         uint32
+	MiterLimit                   float32
+	LineStyle, DashedLineCapType int32
+	DashOffset                   float32
+	DashedLineData               []float32
+	PenAlignment                 int32
+	CompoundLineData             []float32
+	CustomStartCap, CustomEndCap []byte
+
+	Brush PlusBrush
+}
+
+func readPlusPen(reader *bytes.Reader) (PlusPen, error) {
+	r := PlusPen{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Type); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.PenDataFlags); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.PenUnit); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.PenWidth); err != nil {
+		return r, err
+	}
+
+	// optional fields follow in the order of flags
+	fields := []struct {
+		flag uint32
+		data interface{}
+	}{
+		{PENDATA_TRANSFORM, &r.Transform},
+		{PENDATA_STARTCAP, &r.StartCap},
+		{PENDATA_ENDCAP, &r.EndCap},
+		{PENDATA_JOIN, &r.Join},
+		{PENDATA_MITERLIMIT, &r.MiterLimit},
+		{PENDATA_LINESTYLE, &r.LineStyle},
+		{PENDATA_DASHEDLINECAP, &r.DashedLineCapType},
+		{PENDATA_DASHEDLINEOFFSET, &r.DashOffset},
+		{PENDATA_DASHEDLINE, &r.DashedLineData},
+		{PENDATA_NONCENTER, &r.PenAlignment},
+		{PENDATA_COMPOUNDLINE, &r.CompoundLineData},
+		{PENDATA_CUSTOMSTARTCAP, &r.CustomStartCap},
+		{PENDATA_CUSTOMENDCAP, &r.CustomEndCap},
+	}
+	for _, f := range fields {
+		if r.PenDataFlags&f.flag == 0 {
+			continue
+		}
+
+		var err error
+		switch data := f.data.(type) {
+		case *[]float32:
+			*data, err = readPlusFloats(reader)
+		case *[]byte:
+			*data, err = readPlusBytes(reader)
+		default:
+			err = binary.Read(reader, binary.LittleEndian, data)
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+
+	var err error
+	r.Brush, err = readPlusBrush(reader)
+	return r, err
+}
+
+// readPlusFloats reads array of floats prefixed with the number of items
+func readPlusFloats(reader *bytes.Reader) ([]float32, error) {
+	var n uint32
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if int(n) > reader.Len()/4 {
+		return nil, fmt.Errorf("invalid number of items %d", n)
+	}
+
+	data := make([]float32, n)
+	err := binary.Read(reader, binary.LittleEndian, &data)
+	return data, err
+}
+
+// readPlusBytes reads data prefixed with its size
+func readPlusBytes(reader *bytes.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if int(n) > reader.Len() {
+		return nil, fmt.Errorf("invalid data size %d", n)
+	}
+
+	data := make([]byte, n)
+	_, err := io.ReadFull(reader, data)
+	return data, err
+}
+
+// readRest reads the rest of object data
+func readRest(reader *bytes.Reader) []byte {
+	data := make([]byte, reader.Len())
+	reader.Read(data)
+	return data
+}
+
+// PlusPath is EMF+ path object
+type PlusPath struct {
+	Version uint32
+	Points  []PlusPointF
+	// PATHPOINTTYPE_* type in lower 4 bits with flags
+	Types []uint8
+}
+
+func readPlusPath(reader *bytes.Reader) (PlusPath, error) {
+	r := PlusPath{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	var count, flags uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &flags); err != nil {
+		return r, err
+	}
+
+	var err error
+	r.Points, err = readPlusPoints(reader, count, uint16(flags))
+	if err != nil {
+		return r, err
+	}
+
+	if flags&PATHPOINTFLAG_RLE == 0 {
+		r.Types = make([]uint8, count)
+		_, err := io.ReadFull(reader, r.Types)
+		return r, err
+	}
+
+	// run-length encoded types have number of points
+	// in lower 6 bits of the first byte and type in the second one
+	for len(r.Types) < int(count) {
+		var rle [2]uint8
+		if _, err := io.ReadFull(reader, rle[:]); err != nil {
+			return r, err
+		}
+		for i := 0; i < int(rle[0]&0x3f) && len(r.Types) < int(count); i++ {
+			r.Types = append(r.Types, rle[1])
+		}
+		if rle[0]&0x3f == 0 {
+			return r, fmt.Errorf("invalid run-length encoded path point type")
+		}
+	}
+
+	return r, nil
+}
+
+// PlusRegionNode is a node of EMF+ region object, combine nodes
+// have left and right child nodes
+type PlusRegionNode struct {
+	Type        uint32
+	Left, Right *PlusRegionNode
+	// rectangle of REGIONNODEDATATYPE_RECT node
+	Rect PlusRectF
+	// path of REGIONNODEDATATYPE_PATH node
+	Path *PlusPath
+}
+
+// PlusRegion is EMF+ region object
+type PlusRegion struct {
+	Version uint32
+	Node    PlusRegionNode
+}
+
+func readPlusRegion(reader *bytes.Reader) (PlusRegion, error) {
+	r := PlusRegion{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	// RegionNodeCount
+	reader.Seek(4, io.SeekCurrent)
+
+	var err error
+	r.Node, err = readPlusRegionNode(reader)
+	return r, err
+}
+
+func readPlusRegionNode(reader *bytes.Reader) (PlusRegionNode, error) {
+	r := PlusRegionNode{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Type); err != nil {
+		return r, err
+	}
+
+	switch r.Type {
+	case REGIONNODEDATATYPE_AND, REGIONNODEDATATYPE_OR, REGIONNODEDATATYPE_XOR,
+		REGIONNODEDATATYPE_EXCLUDE, REGIONNODEDATATYPE_COMPLEMENT:
+		left, err := readPlusRegionNode(reader)
+		if err != nil {
+			return r, err
+		}
+		right, err := readPlusRegionNode(reader)
+		if err != nil {
+			return r, err
+		}
+		r.Left, r.Right = &left, &right
+	case REGIONNODEDATATYPE_RECT:
+		if err := binary.Read(reader, binary.LittleEndian, &r.Rect); err != nil {
+			return r, err
+		}
+	case REGIONNODEDATATYPE_PATH:
+		data, err := readPlusBytes(reader)
+		if err != nil {
+			return r, err
+		}
+		path, err := readPlusPath(bytes.NewReader(data))
+		if err != nil {
+			return r, err
+		}
+		r.Path = &path
+	case REGIONNODEDATATYPE_EMPTY, REGIONNODEDATATYPE_INFINITE:
+	default:
+		return r, fmt.Errorf("unknown region node type %#v", r.Type)
+	}
+
+	return r, nil
+}
+
+// PlusImage is EMF+ image object, it's either a bitmap or a metafile
+type PlusImage struct {
+	Version, Type uint32
+
+	// bitmap image
+	Width, Height, Stride int32
+	PixelFormat           uint32
+	// BitmapDataType is 0 for pixel data and 1 for compressed image
+	BitmapDataType uint32
+
+	// metafile image
+	MetafileType uint32
+
+	// pixel data, compressed image or metafile
+	Data []byte
+}
+
+func readPlusImage(reader *bytes.Reader) (PlusImage, error) {
+	r := PlusImage{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Type); err != nil {
+		return r, err
+	}
+
+	switch r.Type {
+	case IMAGEDATATYPE_BITMAP:
+		if err := binary.Read(reader, binary.LittleEndian, &r.Width); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Height); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Stride); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.PixelFormat); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.BitmapDataType); err != nil {
+			return r, err
+		}
+		r.Data = readRest(reader)
+	case IMAGEDATATYPE_METAFILE:
+		if err := binary.Read(reader, binary.LittleEndian, &r.MetafileType); err != nil {
+			return r, err
+		}
+		var err error
+		r.Data, err = readPlusBytes(reader)
+		if err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// PlusFont is EMF+ font object
+type PlusFont struct {
+	Version        uint32
+	EmSize         float32
+	SizeUnit       uint32
+	FontStyleFlags int32
+	FamilyName     string
+}
+
+func readPlusFont(reader *bytes.Reader) (PlusFont, error) {
+	r := PlusFont{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.EmSize); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.SizeUnit); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.FontStyleFlags); err != nil {
+		return r, err
+	}
+
+	// Reserved
+	reader.Seek(4, io.SeekCurrent)
+
+	var n uint32
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return r, err
+	}
+	if int(n) > reader.Len()/2 {
+		return r, fmt.Errorf("invalid font family name length %d", n)
+	}
+
+	var err error
+	r.FamilyName, err = readWideString(reader, int(n))
+	return r, err
+}
@@ -0,0 +1,1115 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"unicode/utf16"
+
+	"github.com/llgcode/draw2d"
+	"golang.org/x/image/draw"
+)
+
+// objectID returns object index stored in lower byte of record flags
+func (r *PlusRecord) objectID() uint8 {
+	return uint8(r.Flags)
+}
+
+type PlusEndoffileRecord struct {
+	PlusRecord
+}
+
+func readPlusEndoffileRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusEndoffileRecord{rec}, nil
+}
+
+func (r *PlusEndoffileRecord) Draw(ctx *context) {}
+
+type PlusGetdcRecord struct {
+	PlusRecord
+}
+
+func readPlusGetdcRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusGetdcRecord{rec}, nil
+}
+
+type PlusClearRecord struct {
+	PlusRecord
+	Color PlusARGB
+}
+
+func readPlusClearRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusClearRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusClearRecord) Draw(ctx *context) {
+	mask := ctx.plus.clip
+	if mask == nil {
+		mask = fullMask(ctx.w, ctx.h)
+	}
+	draw.DrawMask(ctx.img, mask.Bounds(), image.NewUniform(r.Color.GetColor()), image.Point{},
+		mask, image.Point{}, draw.Src)
+}
+
+// plusFillRecord is a common part of records filled with brush
+type plusFillRecord struct {
+	PlusRecord
+	// brush object index or ARGB color when PLUSFLAG_SOLIDCOLOR is set
+	BrushID uint32
+}
+
+func readPlusFillRecord(reader *bytes.Reader, rec PlusRecord) (plusFillRecord, error) {
+	r := plusFillRecord{PlusRecord: rec}
+	err := binary.Read(reader, binary.LittleEndian, &r.BrushID)
+	return r, err
+}
+
+func (r *plusFillRecord) brush() (flags uint16, id uint32) {
+	return r.Flags, r.BrushID
+}
+
+type PlusFillrectsRecord struct {
+	plusFillRecord
+	Rects []PlusRectF
+}
+
+func readPlusFillrectsRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillrectsRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	r.Rects, err = readPlusRects(reader, count, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillrectsRecord) Draw(ctx *context) {
+	ctx.plusFill(r.Flags, r.BrushID, draw2d.FillRuleWinding, plusRectsPath(r.Rects))
+}
+
+type PlusDrawrectsRecord struct {
+	PlusRecord
+	Rects []PlusRectF
+}
+
+func readPlusDrawrectsRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusDrawrectsRecord{PlusRecord: rec}
+
+	var count uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.Rects, err = readPlusRects(reader, count, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawrectsRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), plusRectsPath(r.Rects))
+}
+
+// readPlusPointList reads number of points followed by points
+func readPlusPointList(reader *bytes.Reader, flags uint16) ([]PlusPointF, error) {
+	var count uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	return readPlusPoints(reader, count, flags)
+}
+
+type PlusFillpolygonRecord struct {
+	plusFillRecord
+	Points []PlusPointF
+}
+
+func readPlusFillpolygonRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillpolygonRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillpolygonRecord) Draw(ctx *context) {
+	ctx.plusFill(r.Flags, r.BrushID, draw2d.FillRuleEvenOdd, plusLinesPath(r.Points, true))
+}
+
+type PlusDrawlinesRecord struct {
+	PlusRecord
+	Points []PlusPointF
+}
+
+func readPlusDrawlinesRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawlinesRecord{PlusRecord: rec}
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawlinesRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), plusLinesPath(r.Points, r.Flags&PLUSFLAG_CLOSED != 0))
+}
+
+type PlusDrawbeziersRecord struct {
+	PlusRecord
+	Points []PlusPointF
+}
+
+func readPlusDrawbeziersRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawbeziersRecord{PlusRecord: rec}
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawbeziersRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), plusBeziersPath(r.Points))
+}
+
+type PlusFillellipseRecord struct {
+	plusFillRecord
+	Rect PlusRectF
+}
+
+func readPlusFillellipseRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillellipseRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Rect, err = readPlusRect(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillellipseRecord) Draw(ctx *context) {
+	ctx.plusFill(r.Flags, r.BrushID, draw2d.FillRuleWinding, plusEllipsePath(r.Rect))
+}
+
+type PlusDrawellipseRecord struct {
+	PlusRecord
+	Rect PlusRectF
+}
+
+func readPlusDrawellipseRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawellipseRecord{PlusRecord: rec}
+	r.Rect, err = readPlusRect(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawellipseRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), plusEllipsePath(r.Rect))
+}
+
+// plusArc is an arc of the ellipse bounded by rectangle,
+// angles are in degrees clockwise from the x axis
+type plusArc struct {
+	StartAngle, SweepAngle float32
+	Rect                   PlusRectF
+}
+
+func readPlusArc(reader *bytes.Reader, flags uint16) (plusArc, error) {
+	r := plusArc{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.StartAngle); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.SweepAngle); err != nil {
+		return r, err
+	}
+
+	var err error
+	r.Rect, err = readPlusRect(reader, flags)
+	return r, err
+}
+
+type PlusFillpieRecord struct {
+	plusFillRecord
+	plusArc
+}
+
+func readPlusFillpieRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillpieRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.plusArc, err = readPlusArc(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillpieRecord) Draw(ctx *context) {
+	ctx.plusFill(r.Flags, r.BrushID, draw2d.FillRuleWinding, r.path(true))
+}
+
+type PlusDrawpieRecord struct {
+	PlusRecord
+	plusArc
+}
+
+func readPlusDrawpieRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawpieRecord{PlusRecord: rec}
+	r.plusArc, err = readPlusArc(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawpieRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), r.path(true))
+}
+
+type PlusDrawarcRecord struct {
+	PlusRecord
+	plusArc
+}
+
+func readPlusDrawarcRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawarcRecord{PlusRecord: rec}
+	r.plusArc, err = readPlusArc(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawarcRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), r.path(false))
+}
+
+type PlusFillregionRecord struct {
+	plusFillRecord
+}
+
+func readPlusFillregionRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillregionRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillregionRecord) Draw(ctx *context) {
+	rgn, ok := ctx.plus.objects[r.objectID()].(PlusRegion)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ region %d not found\n", r.objectID())
+		return
+	}
+
+	mask := ctx.plusRegionMask(&rgn.Node)
+	ctx.plusBegin()
+	defer ctx.plusEnd()
+
+	if src := ctx.plusBrush(r.Flags, r.BrushID); src != nil {
+		ctx.paintMask(mask, src)
+	}
+}
+
+type PlusFillpathRecord struct {
+	plusFillRecord
+}
+
+func readPlusFillpathRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillpathRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillpathRecord) Draw(ctx *context) {
+	path, ok := ctx.plus.objects[r.objectID()].(PlusPath)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ path %d not found\n", r.objectID())
+		return
+	}
+	ctx.plusFill(r.Flags, r.BrushID, draw2d.FillRuleEvenOdd, closeFigures(path.path()))
+}
+
+type PlusDrawpathRecord struct {
+	PlusRecord
+	PenID uint32
+}
+
+func readPlusDrawpathRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusDrawpathRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.PenID); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawpathRecord) Draw(ctx *context) {
+	path, ok := ctx.plus.objects[r.objectID()].(PlusPath)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ path %d not found\n", r.objectID())
+		return
+	}
+	ctx.plusStroke(uint8(r.PenID), path.path())
+}
+
+type PlusFillclosedcurveRecord struct {
+	plusFillRecord
+	Tension float32
+	Points  []PlusPointF
+}
+
+func readPlusFillclosedcurveRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusFillclosedcurveRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Tension); err != nil {
+		return nil, err
+	}
+
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusFillclosedcurveRecord) Draw(ctx *context) {
+	fillRule := draw2d.FillRuleEvenOdd
+	if r.Flags&PLUSFLAG_WINDING != 0 {
+		fillRule = draw2d.FillRuleWinding
+	}
+	ctx.plusFill(r.Flags, r.BrushID, fillRule, plusCurvePath(r.Points, r.Tension, 0, 0, true))
+}
+
+type PlusDrawclosedcurveRecord struct {
+	PlusRecord
+	Tension float32
+	Points  []PlusPointF
+}
+
+func readPlusDrawclosedcurveRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusDrawclosedcurveRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Tension); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawclosedcurveRecord) Draw(ctx *context) {
+	ctx.plusStroke(r.objectID(), plusCurvePath(r.Points, r.Tension, 0, 0, true))
+}
+
+type PlusDrawcurveRecord struct {
+	PlusRecord
+	Tension             float32
+	Offset, NumSegments uint32
+	Points              []PlusPointF
+}
+
+func readPlusDrawcurveRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusDrawcurveRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Tension); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Offset); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.NumSegments); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawcurveRecord) Draw(ctx *context) {
+	path := plusCurvePath(r.Points, r.Tension, int(r.Offset), int(r.NumSegments), false)
+	ctx.plusStroke(r.objectID(), path)
+}
+
+// plusImageRecord is a common part of image drawing records
+type plusImageRecord struct {
+	PlusRecord
+	ImageAttributesID uint32
+	SrcUnit           int32
+	SrcRect           PlusRectF
+}
+
+func readPlusImageRecord(reader *bytes.Reader, rec PlusRecord) (plusImageRecord, error) {
+	r := plusImageRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ImageAttributesID); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.SrcUnit); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.SrcRect); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+type PlusDrawimageRecord struct {
+	plusImageRecord
+	Rect PlusRectF
+}
+
+func readPlusDrawimageRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawimageRecord{}
+	r.plusImageRecord, err = readPlusImageRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Rect, err = readPlusRect(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawimageRecord) Draw(ctx *context) {
+	ctx.plusDrawImage(r.objectID(), r.SrcRect, []PlusPointF{
+		{r.Rect.X, r.Rect.Y},
+		{r.Rect.X + r.Rect.Width, r.Rect.Y},
+		{r.Rect.X, r.Rect.Y + r.Rect.Height},
+	})
+}
+
+type PlusDrawimagepointsRecord struct {
+	plusImageRecord
+	// upper-left, upper-right and lower-left corners of destination
+	Points []PlusPointF
+}
+
+func readPlusDrawimagepointsRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawimagepointsRecord{}
+	r.plusImageRecord, err = readPlusImageRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Points, err = readPlusPointList(reader, rec.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Points) != 3 {
+		return nil, fmt.Errorf("invalid number of image points %d", len(r.Points))
+	}
+
+	return r, nil
+}
+
+func (r *PlusDrawimagepointsRecord) Draw(ctx *context) {
+	ctx.plusDrawImage(r.objectID(), r.SrcRect, r.Points)
+}
+
+type PlusDrawstringRecord struct {
+	plusFillRecord
+	FormatID   uint32
+	LayoutRect PlusRectF
+	String     string
+}
+
+func readPlusDrawstringRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawstringRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.FormatID); err != nil {
+		return nil, err
+	}
+
+	var length uint32
+	if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.LayoutRect); err != nil {
+		return nil, err
+	}
+
+	if int(length) > reader.Len()/2 {
+		return nil, fmt.Errorf("invalid string length %d", length)
+	}
+
+	s := make([]uint16, length)
+	if err := binary.Read(reader, binary.LittleEndian, &s); err != nil {
+		return nil, err
+	}
+	r.String = string(utf16.Decode(s))
+
+	return r, nil
+}
+
+type PlusDrawdriverstringRecord struct {
+	plusFillRecord
+	DriverStringOptionsFlags uint32
+	MatrixPresent            uint32
+	Glyphs                   []uint16
+	GlyphPos                 []PlusPointF
+	// transformation of glyphs when MatrixPresent is set
+	Matrix [6]float32
+}
+
+func readPlusDrawdriverstringRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	var err error
+	r := &PlusDrawdriverstringRecord{}
+	r.plusFillRecord, err = readPlusFillRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.DriverStringOptionsFlags); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.MatrixPresent); err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	if int(count) > reader.Len()/10 {
+		return nil, fmt.Errorf("invalid number of glyphs %d", count)
+	}
+
+	r.Glyphs = make([]uint16, count)
+	if err := binary.Read(reader, binary.LittleEndian, &r.Glyphs); err != nil {
+		return nil, err
+	}
+
+	r.GlyphPos = make([]PlusPointF, count)
+	if err := binary.Read(reader, binary.LittleEndian, &r.GlyphPos); err != nil {
+		return nil, err
+	}
+
+	if r.MatrixPresent != 0 {
+		if err := binary.Read(reader, binary.LittleEndian, &r.Matrix); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// plusStackRecord is a common part of records saving
+// and restoring graphics state
+type plusStackRecord struct {
+	PlusRecord
+	StackIndex uint32
+}
+
+func readPlusStackRecord(reader *bytes.Reader, rec PlusRecord) (plusStackRecord, error) {
+	r := plusStackRecord{PlusRecord: rec}
+	err := binary.Read(reader, binary.LittleEndian, &r.StackIndex)
+	return r, err
+}
+
+type PlusSaveRecord struct {
+	plusStackRecord
+}
+
+func readPlusSaveRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r, err := readPlusStackRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+	return &PlusSaveRecord{r}, nil
+}
+
+func (r *PlusSaveRecord) Draw(ctx *context) {
+	ctx.plus.saved[r.StackIndex] = ctx.plus.plusState
+}
+
+type PlusRestoreRecord struct {
+	plusStackRecord
+}
+
+func readPlusRestoreRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r, err := readPlusStackRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+	return &PlusRestoreRecord{r}, nil
+}
+
+func (r *PlusRestoreRecord) Draw(ctx *context) {
+	ctx.plus.restore(r.StackIndex)
+}
+
+type PlusBegincontainerRecord struct {
+	PlusRecord
+	DestRect, SrcRect PlusRectF
+	StackIndex        uint32
+}
+
+func readPlusBegincontainerRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusBegincontainerRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.DestRect); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.SrcRect); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.StackIndex); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusBegincontainerRecord) Draw(ctx *context) {
+	ctx.plus.saved[r.StackIndex] = ctx.plus.plusState
+
+	// source rectangle is mapped to destination rectangle
+	if r.SrcRect.Width == 0 || r.SrcRect.Height == 0 {
+		return
+	}
+	m := draw2d.NewTranslationMatrix(float64(r.DestRect.X), float64(r.DestRect.Y))
+	m.Scale(
+		float64(r.DestRect.Width)/float64(r.SrcRect.Width),
+		float64(r.DestRect.Height)/float64(r.SrcRect.Height))
+	m.Translate(-float64(r.SrcRect.X), -float64(r.SrcRect.Y))
+	ctx.plus.world.Compose(m)
+}
+
+type PlusBegincontainernoparamsRecord struct {
+	plusStackRecord
+}
+
+func readPlusBegincontainernoparamsRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r, err := readPlusStackRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+	return &PlusBegincontainernoparamsRecord{r}, nil
+}
+
+func (r *PlusBegincontainernoparamsRecord) Draw(ctx *context) {
+	ctx.plus.saved[r.StackIndex] = ctx.plus.plusState
+}
+
+type PlusEndcontainerRecord struct {
+	plusStackRecord
+}
+
+func readPlusEndcontainerRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r, err := readPlusStackRecord(reader, rec)
+	if err != nil {
+		return nil, err
+	}
+	return &PlusEndcontainerRecord{r}, nil
+}
+
+func (r *PlusEndcontainerRecord) Draw(ctx *context) {
+	ctx.plus.restore(r.StackIndex)
+}
+
+type PlusSetworldtransformRecord struct {
+	PlusRecord
+	Matrix [6]float32
+}
+
+func readPlusSetworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusSetworldtransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Matrix); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusSetworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.world = plusMatrix(r.Matrix)
+}
+
+type PlusResetworldtransformRecord struct {
+	PlusRecord
+}
+
+func readPlusResetworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusResetworldtransformRecord{rec}, nil
+}
+
+func (r *PlusResetworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.world = draw2d.NewIdentityMatrix()
+}
+
+type PlusMultiplyworldtransformRecord struct {
+	PlusRecord
+	Matrix [6]float32
+}
+
+func readPlusMultiplyworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusMultiplyworldtransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Matrix); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusMultiplyworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.multiply(plusMatrix(r.Matrix), r.Flags)
+}
+
+type PlusTranslateworldtransformRecord struct {
+	PlusRecord
+	Dx, Dy float32
+}
+
+func readPlusTranslateworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusTranslateworldtransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Dx); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Dy); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusTranslateworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.multiply(draw2d.NewTranslationMatrix(float64(r.Dx), float64(r.Dy)), r.Flags)
+}
+
+type PlusScaleworldtransformRecord struct {
+	PlusRecord
+	Sx, Sy float32
+}
+
+func readPlusScaleworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusScaleworldtransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Sx); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Sy); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusScaleworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.multiply(draw2d.NewScaleMatrix(float64(r.Sx), float64(r.Sy)), r.Flags)
+}
+
+type PlusRotateworldtransformRecord struct {
+	PlusRecord
+	// angle in degrees
+	Angle float32
+}
+
+func readPlusRotateworldtransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusRotateworldtransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Angle); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusRotateworldtransformRecord) Draw(ctx *context) {
+	ctx.plus.multiply(draw2d.NewRotationMatrix(float64(r.Angle)*math.Pi/180), r.Flags)
+}
+
+type PlusSetpagetransformRecord struct {
+	PlusRecord
+	PageScale float32
+}
+
+func readPlusSetpagetransformRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusSetpagetransformRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.PageScale); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusSetpagetransformRecord) Draw(ctx *context) {
+	ctx.plus.pageUnit = uint32(r.Flags & 0xff)
+	ctx.plus.pageScale = float64(r.PageScale)
+}
+
+// combineMode returns COMBINEMODE_* value of clipping records
+func (r *PlusRecord) combineMode() uint8 {
+	return uint8(r.Flags>>8) & 0x0f
+}
+
+type PlusResetclipRecord struct {
+	PlusRecord
+}
+
+func readPlusResetclipRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusResetclipRecord{rec}, nil
+}
+
+func (r *PlusResetclipRecord) Draw(ctx *context) {
+	ctx.plus.clip = nil
+}
+
+type PlusSetcliprectRecord struct {
+	PlusRecord
+	ClipRect PlusRectF
+}
+
+func readPlusSetcliprectRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusSetcliprectRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ClipRect); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusSetcliprectRecord) Draw(ctx *context) {
+	mask := ctx.plusPathMask(draw2d.FillRuleWinding, plusRectsPath([]PlusRectF{r.ClipRect}))
+	ctx.combinePlusClip(mask, r.combineMode())
+}
+
+type PlusSetclippathRecord struct {
+	PlusRecord
+}
+
+func readPlusSetclippathRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusSetclippathRecord{rec}, nil
+}
+
+func (r *PlusSetclippathRecord) Draw(ctx *context) {
+	path, ok := ctx.plus.objects[r.objectID()].(PlusPath)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ path %d not found\n", r.objectID())
+		return
+	}
+	mask := ctx.plusPathMask(draw2d.FillRuleEvenOdd, closeFigures(path.path()))
+	ctx.combinePlusClip(mask, r.combineMode())
+}
+
+type PlusSetclipregionRecord struct {
+	PlusRecord
+}
+
+func readPlusSetclipregionRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	return &PlusSetclipregionRecord{rec}, nil
+}
+
+func (r *PlusSetclipregionRecord) Draw(ctx *context) {
+	rgn, ok := ctx.plus.objects[r.objectID()].(PlusRegion)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: EMF+ region %d not found\n", r.objectID())
+		return
+	}
+	ctx.combinePlusClip(ctx.plusRegionMask(&rgn.Node), r.combineMode())
+}
+
+type PlusOffsetclipRecord struct {
+	PlusRecord
+	Dx, Dy float32
+}
+
+func readPlusOffsetclipRecord(reader *bytes.Reader, rec PlusRecord) (PlusRecorder, error) {
+	r := &PlusOffsetclipRecord{PlusRecord: rec}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Dx); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Dy); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PlusOffsetclipRecord) Draw(ctx *context) {
+	if ctx.plus.clip == nil {
+		return
+	}
+
+	// offset is in world units
+	tr := ctx.plusTransform()
+	x0, y0 := tr.TransformPoint(0, 0)
+	x1, y1 := tr.TransformPoint(float64(r.Dx), float64(r.Dy))
+	ctx.plus.clip = offsetMask(ctx.plus.clip,
+		int(math.Round(x1-x0)), int(math.Round(y1-y0)))
+}
+
+// map of readers for EMF+ records, records of rendering properties
+// keep their values in flags and have no data
+var plusRecords = map[uint16]func(*bytes.Reader, PlusRecord) (PlusRecorder, error){
+	EMFPLUS_HEADER:                  readPlusHeaderRecord,
+	EMFPLUS_ENDOFFILE:               readPlusEndoffileRecord,
+	EMFPLUS_COMMENT:                 nil,
+	EMFPLUS_GETDC:                   readPlusGetdcRecord,
+	EMFPLUS_MULTIFORMATSTART:        nil,
+	EMFPLUS_MULTIFORMATSECTION:      nil,
+	EMFPLUS_MULTIFORMATEND:          nil,
+	EMFPLUS_OBJECT:                  readPlusObjectRecord,
+	EMFPLUS_CLEAR:                   readPlusClearRecord,
+	EMFPLUS_FILLRECTS:               readPlusFillrectsRecord,
+	EMFPLUS_DRAWRECTS:               readPlusDrawrectsRecord,
+	EMFPLUS_FILLPOLYGON:             readPlusFillpolygonRecord,
+	EMFPLUS_DRAWLINES:               readPlusDrawlinesRecord,
+	EMFPLUS_FILLELLIPSE:             readPlusFillellipseRecord,
+	EMFPLUS_DRAWELLIPSE:             readPlusDrawellipseRecord,
+	EMFPLUS_FILLPIE:                 readPlusFillpieRecord,
+	EMFPLUS_DRAWPIE:                 readPlusDrawpieRecord,
+	EMFPLUS_DRAWARC:                 readPlusDrawarcRecord,
+	EMFPLUS_FILLREGION:              readPlusFillregionRecord,
+	EMFPLUS_FILLPATH:                readPlusFillpathRecord,
+	EMFPLUS_DRAWPATH:                readPlusDrawpathRecord,
+	EMFPLUS_FILLCLOSEDCURVE:         readPlusFillclosedcurveRecord,
+	EMFPLUS_DRAWCLOSEDCURVE:         readPlusDrawclosedcurveRecord,
+	EMFPLUS_DRAWCURVE:               readPlusDrawcurveRecord,
+	EMFPLUS_DRAWBEZIERS:             readPlusDrawbeziersRecord,
+	EMFPLUS_DRAWIMAGE:               readPlusDrawimageRecord,
+	EMFPLUS_DRAWIMAGEPOINTS:         readPlusDrawimagepointsRecord,
+	EMFPLUS_DRAWSTRING:              readPlusDrawstringRecord,
+	EMFPLUS_SETRENDERINGORIGIN:      nil,
+	EMFPLUS_SETANTIALIASMODE:        nil,
+	EMFPLUS_SETTEXTRENDERINGHINT:    nil,
+	EMFPLUS_SETTEXTCONTRAST:         nil,
+	EMFPLUS_SETINTERPOLATIONMODE:    nil,
+	EMFPLUS_SETPIXELOFFSETMODE:      nil,
+	EMFPLUS_SETCOMPOSITINGMODE:      nil,
+	EMFPLUS_SETCOMPOSITINGQUALITY:   nil,
+	EMFPLUS_SAVE:                    readPlusSaveRecord,
+	EMFPLUS_RESTORE:                 readPlusRestoreRecord,
+	EMFPLUS_BEGINCONTAINER:          readPlusBegincontainerRecord,
+	EMFPLUS_BEGINCONTAINERNOPARAMS:  readPlusBegincontainernoparamsRecord,
+	EMFPLUS_ENDCONTAINER:            readPlusEndcontainerRecord,
+	EMFPLUS_SETWORLDTRANSFORM:       readPlusSetworldtransformRecord,
+	EMFPLUS_RESETWORLDTRANSFORM:     readPlusResetworldtransformRecord,
+	EMFPLUS_MULTIPLYWORLDTRANSFORM:  readPlusMultiplyworldtransformRecord,
+	EMFPLUS_TRANSLATEWORLDTRANSFORM: readPlusTranslateworldtransformRecord,
+	EMFPLUS_SCALEWORLDTRANSFORM:     readPlusScaleworldtransformRecord,
+	EMFPLUS_ROTATEWORLDTRANSFORM:    readPlusRotateworldtransformRecord,
+	EMFPLUS_SETPAGETRANSFORM:        readPlusSetpagetransformRecord,
+	EMFPLUS_RESETCLIP:               readPlusResetclipRecord,
+	EMFPLUS_SETCLIPRECT:             readPlusSetcliprectRecord,
+	EMFPLUS_SETCLIPPATH:             readPlusSetclippathRecord,
+	EMFPLUS_SETCLIPREGION:           readPlusSetclipregionRecord,
+	EMFPLUS_OFFSETCLIP:              readPlusOffsetclipRecord,
+	EMFPLUS_DRAWDRIVERSTRING:        readPlusDrawdriverstringRecord,
+	EMFPLUS_STROKEFILLPATH:          nil,
+	EMFPLUS_SERIALIZABLEOBJECT:      nil,
+	EMFPLUS_SETTSGRAPHICS:           nil,
+	EMFPLUS_SETTSCLIP:               nil,
+}
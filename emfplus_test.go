@@ -0,0 +1,252 @@
+package emf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadPlusInteger(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want int32
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x3f}, 63},
+		{[]byte{0x40}, -64},
+		{[]byte{0x7f}, -1},
+		{[]byte{0x80, 0x40}, 64},
+		{[]byte{0xbf, 0xff}, 16383},
+		{[]byte{0xc0, 0x00}, -16384},
+		{[]byte{0xff, 0xff}, -1},
+	}
+
+	for _, tt := range tests {
+		got, err := readPlusInteger(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("readPlusInteger(% x): %v", tt.data, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("readPlusInteger(% x) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+
+	if _, err := readPlusInteger(bytes.NewReader([]byte{0x80})); err == nil {
+		t.Error("readPlusInteger of truncated 15-bit integer: no error")
+	}
+}
+
+func TestReadPlusPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		count uint32
+		flags uint16
+		want  []PlusPointF
+	}{
+		{
+			name:  "float",
+			data:  encode([]float32{1.5, -2, 3, 4}),
+			count: 2,
+			want:  []PlusPointF{{1.5, -2}, {3, 4}},
+		},
+		{
+			name:  "compressed",
+			data:  encode([]int16{-1, 2, 300, -400}),
+			count: 2,
+			flags: PLUSFLAG_COMPRESSED,
+			want:  []PlusPointF{{-1, 2}, {300, -400}},
+		},
+		{
+			name:  "relative",
+			data:  []byte{0x0a, 0x14, 0x7f, 0x80, 0x80, 0xc0, 0x00, 0x00},
+			count: 3,
+			flags: PLUSFLAG_RELATIVE,
+			want:  []PlusPointF{{10, 20}, {9, 148}, {-16375, 148}},
+		},
+		{
+			name:  "relative takes precedence over compressed",
+			data:  []byte{0x01, 0x02},
+			count: 1,
+			flags: PLUSFLAG_RELATIVE | PLUSFLAG_COMPRESSED,
+			want:  []PlusPointF{{1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := readPlusPoints(bytes.NewReader(tt.data), tt.count, tt.flags)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := readPlusPoints(bytes.NewReader([]byte{0, 0}), 100, 0); err == nil {
+		t.Error("readPlusPoints with count exceeding data: no error")
+	}
+}
+
+func TestReadPlusPath(t *testing.T) {
+	points := []float32{0, 0, 10, 0, 10, 10, 0, 10}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		want  []uint8
+		isErr bool
+	}{
+		{
+			name: "plain types",
+			data: encode(uint32(0xdbc01002), uint32(4), uint32(0), points,
+				[]uint8{PATHPOINTTYPE_START, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE | 0x80}),
+			want: []uint8{PATHPOINTTYPE_START, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE | 0x80},
+		},
+		{
+			name: "run-length encoded types",
+			data: encode(uint32(0xdbc01002), uint32(4), uint32(PATHPOINTFLAG_RLE), points,
+				[]uint8{1, PATHPOINTTYPE_START, 3, PATHPOINTTYPE_LINE}),
+			want: []uint8{PATHPOINTTYPE_START, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE, PATHPOINTTYPE_LINE},
+		},
+		{
+			name: "run longer than points",
+			data: encode(uint32(0xdbc01002), uint32(4), uint32(PATHPOINTFLAG_RLE), points,
+				[]uint8{0x80 | 63, PATHPOINTTYPE_BEZIER}),
+			want: []uint8{PATHPOINTTYPE_BEZIER, PATHPOINTTYPE_BEZIER, PATHPOINTTYPE_BEZIER, PATHPOINTTYPE_BEZIER},
+		},
+		{
+			name: "empty run",
+			data: encode(uint32(0xdbc01002), uint32(4), uint32(PATHPOINTFLAG_RLE), points,
+				[]uint8{0, PATHPOINTTYPE_LINE}),
+			isErr: true,
+		},
+		{
+			name: "truncated types",
+			data: encode(uint32(0xdbc01002), uint32(4), uint32(PATHPOINTFLAG_RLE), points,
+				[]uint8{2, PATHPOINTTYPE_LINE}),
+			isErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := readPlusPath(bytes.NewReader(tt.data))
+		if tt.isErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got.Points) != 4 {
+			t.Errorf("%s: got %d points, want 4", tt.name, len(got.Points))
+		}
+		if !reflect.DeepEqual(got.Types, tt.want) {
+			t.Errorf("%s: got types %v, want %v", tt.name, got.Types, tt.want)
+		}
+	}
+}
+
+func TestPlusObjectsJoin(t *testing.T) {
+	// solid brush object split into chunks of continued object records
+	brush := encode(uint32(0xdbc01002), uint32(BRUSHTYPE_SOLIDCOLOR), uint32(0xff112233))
+
+	chunk := func(id uint8, data []byte, continued bool) *PlusObjectRecord {
+		r := &PlusObjectRecord{
+			PlusRecord: PlusRecord{Type: EMFPLUS_OBJECT, Flags: OBJECTTYPE_BRUSH<<8 | uint16(id)},
+			ObjectID:   id,
+			ObjectType: OBJECTTYPE_BRUSH,
+			Data:       data,
+		}
+		if continued {
+			r.Flags |= PLUSFLAG_CONTINUE
+			r.TotalObjectSize = uint32(len(brush))
+		}
+		return r
+	}
+
+	other := &PlusRecord{Type: EMFPLUS_CLEAR}
+
+	tests := []struct {
+		name string
+		// records of consecutive comments
+		comments [][]PlusRecorder
+		// number of records in each joined comment
+		want []int
+	}{
+		{
+			name:     "single record",
+			comments: [][]PlusRecorder{{chunk(1, brush, false), other}},
+			want:     []int{2},
+		},
+		{
+			name: "chunks in one comment",
+			comments: [][]PlusRecorder{{
+				chunk(1, brush[:4], true), chunk(1, brush[4:8], true), chunk(1, brush[8:], true), other,
+			}},
+			want: []int{2},
+		},
+		{
+			name: "chunks across comments",
+			comments: [][]PlusRecorder{
+				{other, chunk(2, brush[:5], true)},
+				{chunk(2, brush[5:], true), other},
+			},
+			want: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		p := &plusObjects{}
+		var objects []*PlusObjectRecord
+		for i, recs := range tt.comments {
+			joined := p.join(recs)
+			if len(joined) != tt.want[i] {
+				t.Errorf("%s: comment %d has %d records, want %d", tt.name, i, len(joined), tt.want[i])
+			}
+			for _, rec := range joined {
+				if obj, ok := rec.(*PlusObjectRecord); ok {
+					objects = append(objects, obj)
+				}
+			}
+		}
+
+		if len(objects) != 1 {
+			t.Errorf("%s: got %d objects, want 1", tt.name, len(objects))
+			continue
+		}
+		if !bytes.Equal(objects[0].Data, brush) {
+			t.Errorf("%s: got data % x, want % x", tt.name, objects[0].Data, brush)
+		}
+		b, ok := objects[0].Object.(PlusBrush)
+		if !ok || b.Color != (PlusARGB{0x33, 0x22, 0x11, 0xff}) {
+			t.Errorf("%s: got object %#v", tt.name, objects[0].Object)
+		}
+	}
+}
+
+func TestReadPlusRecords(t *testing.T) {
+	fill := encode(PlusRecord{EMFPLUS_FILLRECTS, PLUSFLAG_SOLIDCOLOR, 36, 24},
+		uint32(0xff0000ff), uint32(1), PlusRectF{10, 10, 30, 30})
+	// record size exceeds the comment
+	clear := encode(PlusRecord{EMFPLUS_CLEAR, 0, 400, 4}, uint32(0xff0000ff))
+
+	recs, err := readPlusRecords(append(fill, clear...))
+	if err == nil {
+		t.Error("no error for invalid record size")
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if _, ok := recs[0].(*PlusFillrectsRecord); !ok {
+		t.Errorf("got first record %T, want *PlusFillrectsRecord", recs[0])
+	}
+	if r := recs[1].plusRecord(); r.Type != EMFPLUS_CLEAR {
+		t.Errorf("got invalid record %+v, want EMFPLUS_CLEAR", r)
+	}
+}
@@ -1,7 +1,10 @@
 package emf
 
 // FormatSignature
-const ENHMETA_SIGNATURE = 0x464D4520
+const (
+	ENHMETA_SIGNATURE = 0x464D4520
+	EPS_SIGNATURE     = 0x46535045
+)
 
 // RecordType
 const (
@@ -233,6 +236,9 @@ const (
 	BI_CMYK      = 0x000B
 	BI_CMYKRLE8  = 0x000C
 	BI_CMYKRLE4  = 0x000D
+
+	// BI_BITFIELDS with alpha mask, it's not in MS-WMF
+	BI_ALPHABITFIELDS = 0x0006
 )
 
 // MapMode
@@ -271,6 +277,19 @@ const (
 	RGN_COPY = 0x05
 )
 
+// Layout
+const (
+	LAYOUT_LTR                        = 0x00000000
+	LAYOUT_RTL                        = 0x00000001
+	LAYOUT_BITMAPORIENTATIONPRESERVED = 0x00000008
+)
+
+// FloodFill
+const (
+	FLOODFILLBORDER  = 0x00000000
+	FLOODFILLSURFACE = 0x00000001
+)
+
 // BrushStyle
 const (
 	BS_SOLID         = 0x0000
@@ -284,3 +303,385 @@ const (
 	BS_DIBPATTERN8X8 = 0x0008
 	BS_MONOPATTERN   = 0x0009
 )
+
+// TextAlignmentMode
+const (
+	TA_NOUPDATECP = 0x0000
+	TA_LEFT       = 0x0000
+	TA_TOP        = 0x0000
+	TA_UPDATECP   = 0x0001
+	TA_RIGHT      = 0x0002
+	TA_CENTER     = 0x0006
+	TA_BOTTOM     = 0x0008
+	TA_BASELINE   = 0x0018
+	TA_RTLREADING = 0x0100
+)
+
+// VerticalTextAlignmentMode
+const (
+	VTA_TOP      = 0x0000
+	VTA_RIGHT    = 0x0000
+	VTA_BOTTOM   = 0x0002
+	VTA_CENTER   = 0x0006
+	VTA_LEFT     = 0x0008
+	VTA_BASELINE = 0x0018
+)
+
+// FamilyFont
+const (
+	FF_DONTCARE   = 0x00
+	FF_ROMAN      = 0x01
+	FF_SWISS      = 0x02
+	FF_MODERN     = 0x03
+	FF_SCRIPT     = 0x04
+	FF_DECORATIVE = 0x05
+)
+
+// PitchFont
+const (
+	DEFAULT_PITCH  = 0x00
+	FIXED_PITCH    = 0x01
+	VARIABLE_PITCH = 0x02
+)
+
+// FontWeight
+const (
+	FW_DONTCARE   = 0
+	FW_THIN       = 100
+	FW_EXTRALIGHT = 200
+	FW_LIGHT      = 300
+	FW_NORMAL     = 400
+	FW_MEDIUM     = 500
+	FW_SEMIBOLD   = 600
+	FW_BOLD       = 700
+	FW_EXTRABOLD  = 800
+	FW_HEAVY      = 900
+)
+
+// CharacterSet
+const (
+	ANSI_CHARSET        = 0x00
+	DEFAULT_CHARSET     = 0x01
+	SYMBOL_CHARSET      = 0x02
+	MAC_CHARSET         = 0x4D
+	SHIFTJIS_CHARSET    = 0x80
+	HANGUL_CHARSET      = 0x81
+	JOHAB_CHARSET       = 0x82
+	GB2312_CHARSET      = 0x86
+	CHINESEBIG5_CHARSET = 0x88
+	GREEK_CHARSET       = 0xA1
+	TURKISH_CHARSET     = 0xA2
+	VIETNAMESE_CHARSET  = 0xA3
+	HEBREW_CHARSET      = 0xB1
+	ARABIC_CHARSET      = 0xB2
+	BALTIC_CHARSET      = 0xBA
+	RUSSIAN_CHARSET     = 0xCC
+	THAI_CHARSET        = 0xDE
+	EASTEUROPE_CHARSET  = 0xEE
+	OEM_CHARSET         = 0xFF
+)
+
+// MapperFlags
+const (
+	ASPECT_FILTERING = 0x00000001
+)
+
+// ICMMode
+const (
+	ICM_OFF            = 0x01
+	ICM_ON             = 0x02
+	ICM_QUERY          = 0x03
+	ICM_DONE_OUTSIDEDC = 0x04
+)
+
+// LogicalColorSpace
+const (
+	LCS_CALIBRATED_RGB      = 0x00000000
+	LCS_sRGB                = 0x73524742
+	LCS_WINDOWS_COLOR_SPACE = 0x57696E20
+	PROFILE_LINKED          = 0x4C494E4B
+	PROFILE_EMBEDDED        = 0x4D424544
+)
+
+// ColorSpace
+const (
+	CS_ENABLE           = 0x00000001
+	CS_DISABLE          = 0x00000002
+	CS_DELETE_TRANSFORM = 0x00000003
+)
+
+// flags of color space and ICM profile records
+const (
+	CREATECOLORSPACE_EMBEDDED   = 0x00000001
+	SETICMPROFILE_EMBEDDED      = 0x00000001
+	COLORMATCHTOTARGET_EMBEDDED = 0x00000001
+)
+
+// ColorAdjustment
+const (
+	CA_NEGATIVE   = 0x0001
+	CA_LOG_FILTER = 0x0002
+)
+
+// Illuminant
+const (
+	ILLUMINANT_DEVICE_DEFAULT = 0x0000
+	ILLUMINANT_TUNGSTEN       = 0x0001
+	ILLUMINANT_B              = 0x0002
+	ILLUMINANT_DAYLIGHT       = 0x0003
+	ILLUMINANT_D50            = 0x0004
+	ILLUMINANT_D55            = 0x0005
+	ILLUMINANT_D65            = 0x0006
+	ILLUMINANT_D75            = 0x0007
+	ILLUMINANT_FLUORESCENT    = 0x0008
+)
+
+// CommentIdentifier
+const (
+	EMR_COMMENT_EMFSPOOL = 0x00000000
+	EMR_COMMENT_EMFPLUS  = 0x2B464D45
+	EMR_COMMENT_PUBLIC   = 0x43494447
+)
+
+// EmrComment
+const (
+	EMR_COMMENT_WINDOWS_METAFILE = 0x80000001
+	EMR_COMMENT_BEGINGROUP       = 0x00000002
+	EMR_COMMENT_ENDGROUP         = 0x00000003
+	EMR_COMMENT_MULTIFORMATS     = 0x40000004
+	EMR_COMMENT_UNICODE_STRING   = 0x00000040
+	EMR_COMMENT_UNICODE_END      = 0x00000080
+)
+
+// EmfPlusRecordType
+const (
+	EMFPLUS_HEADER                  = 0x4001
+	EMFPLUS_ENDOFFILE               = 0x4002
+	EMFPLUS_COMMENT                 = 0x4003
+	EMFPLUS_GETDC                   = 0x4004
+	EMFPLUS_MULTIFORMATSTART        = 0x4005
+	EMFPLUS_MULTIFORMATSECTION      = 0x4006
+	EMFPLUS_MULTIFORMATEND          = 0x4007
+	EMFPLUS_OBJECT                  = 0x4008
+	EMFPLUS_CLEAR                   = 0x4009
+	EMFPLUS_FILLRECTS               = 0x400A
+	EMFPLUS_DRAWRECTS               = 0x400B
+	EMFPLUS_FILLPOLYGON             = 0x400C
+	EMFPLUS_DRAWLINES               = 0x400D
+	EMFPLUS_FILLELLIPSE             = 0x400E
+	EMFPLUS_DRAWELLIPSE             = 0x400F
+	EMFPLUS_FILLPIE                 = 0x4010
+	EMFPLUS_DRAWPIE                 = 0x4011
+	EMFPLUS_DRAWARC                 = 0x4012
+	EMFPLUS_FILLREGION              = 0x4013
+	EMFPLUS_FILLPATH                = 0x4014
+	EMFPLUS_DRAWPATH                = 0x4015
+	EMFPLUS_FILLCLOSEDCURVE         = 0x4016
+	EMFPLUS_DRAWCLOSEDCURVE         = 0x4017
+	EMFPLUS_DRAWCURVE               = 0x4018
+	EMFPLUS_DRAWBEZIERS             = 0x4019
+	EMFPLUS_DRAWIMAGE               = 0x401A
+	EMFPLUS_DRAWIMAGEPOINTS         = 0x401B
+	EMFPLUS_DRAWSTRING              = 0x401C
+	EMFPLUS_SETRENDERINGORIGIN      = 0x401D
+	EMFPLUS_SETANTIALIASMODE        = 0x401E
+	EMFPLUS_SETTEXTRENDERINGHINT    = 0x401F
+	EMFPLUS_SETTEXTCONTRAST         = 0x4020
+	EMFPLUS_SETINTERPOLATIONMODE    = 0x4021
+	EMFPLUS_SETPIXELOFFSETMODE      = 0x4022
+	EMFPLUS_SETCOMPOSITINGMODE      = 0x4023
+	EMFPLUS_SETCOMPOSITINGQUALITY   = 0x4024
+	EMFPLUS_SAVE                    = 0x4025
+	EMFPLUS_RESTORE                 = 0x4026
+	EMFPLUS_BEGINCONTAINER          = 0x4027
+	EMFPLUS_BEGINCONTAINERNOPARAMS  = 0x4028
+	EMFPLUS_ENDCONTAINER            = 0x4029
+	EMFPLUS_SETWORLDTRANSFORM       = 0x402A
+	EMFPLUS_RESETWORLDTRANSFORM     = 0x402B
+	EMFPLUS_MULTIPLYWORLDTRANSFORM  = 0x402C
+	EMFPLUS_TRANSLATEWORLDTRANSFORM = 0x402D
+	EMFPLUS_SCALEWORLDTRANSFORM     = 0x402E
+	EMFPLUS_ROTATEWORLDTRANSFORM    = 0x402F
+	EMFPLUS_SETPAGETRANSFORM        = 0x4030
+	EMFPLUS_RESETCLIP               = 0x4031
+	EMFPLUS_SETCLIPRECT             = 0x4032
+	EMFPLUS_SETCLIPPATH             = 0x4033
+	EMFPLUS_SETCLIPREGION           = 0x4034
+	EMFPLUS_OFFSETCLIP              = 0x4035
+	EMFPLUS_DRAWDRIVERSTRING        = 0x4036
+	EMFPLUS_STROKEFILLPATH          = 0x4037
+	EMFPLUS_SERIALIZABLEOBJECT      = 0x4038
+	EMFPLUS_SETTSGRAPHICS           = 0x4039
+	EMFPLUS_SETTSCLIP               = 0x403A
+)
+
+// ObjectType
+const (
+	OBJECTTYPE_INVALID         = 0x00
+	OBJECTTYPE_BRUSH           = 0x01
+	OBJECTTYPE_PEN             = 0x02
+	OBJECTTYPE_PATH            = 0x03
+	OBJECTTYPE_REGION          = 0x04
+	OBJECTTYPE_IMAGE           = 0x05
+	OBJECTTYPE_FONT            = 0x06
+	OBJECTTYPE_STRINGFORMAT    = 0x07
+	OBJECTTYPE_IMAGEATTRIBUTES = 0x08
+	OBJECTTYPE_CUSTOMLINECAP   = 0x09
+)
+
+// BrushType
+const (
+	BRUSHTYPE_SOLIDCOLOR     = 0x00
+	BRUSHTYPE_HATCHFILL      = 0x01
+	BRUSHTYPE_TEXTUREFILL    = 0x02
+	BRUSHTYPE_PATHGRADIENT   = 0x03
+	BRUSHTYPE_LINEARGRADIENT = 0x04
+)
+
+// PenData
+const (
+	PENDATA_TRANSFORM        = 0x0001
+	PENDATA_STARTCAP         = 0x0002
+	PENDATA_ENDCAP           = 0x0004
+	PENDATA_JOIN             = 0x0008
+	PENDATA_MITERLIMIT       = 0x0010
+	PENDATA_LINESTYLE        = 0x0020
+	PENDATA_DASHEDLINECAP    = 0x0040
+	PENDATA_DASHEDLINEOFFSET = 0x0080
+	PENDATA_DASHEDLINE       = 0x0100
+	PENDATA_NONCENTER        = 0x0200
+	PENDATA_COMPOUNDLINE     = 0x0400
+	PENDATA_CUSTOMSTARTCAP   = 0x0800
+	PENDATA_CUSTOMENDCAP     = 0x1000
+)
+
+// ImageDataType
+const (
+	IMAGEDATATYPE_BITMAP   = 0x01
+	IMAGEDATATYPE_METAFILE = 0x02
+)
+
+// PathPointType
+const (
+	PATHPOINTTYPE_START  = 0x00
+	PATHPOINTTYPE_LINE   = 0x01
+	PATHPOINTTYPE_BEZIER = 0x03
+	// flags of path point type
+	PATHPOINTTYPE_DASHMODE     = 0x10
+	PATHPOINTTYPE_PATHMARKER   = 0x20
+	PATHPOINTTYPE_CLOSESUBPATH = 0x80
+)
+
+// RegionNodeDataType
+const (
+	REGIONNODEDATATYPE_AND        = 0x00000001
+	REGIONNODEDATATYPE_OR         = 0x00000002
+	REGIONNODEDATATYPE_XOR        = 0x00000003
+	REGIONNODEDATATYPE_EXCLUDE    = 0x00000004
+	REGIONNODEDATATYPE_COMPLEMENT = 0x00000005
+	REGIONNODEDATATYPE_RECT       = 0x10000000
+	REGIONNODEDATATYPE_PATH       = 0x10000001
+	REGIONNODEDATATYPE_EMPTY      = 0x10000002
+	REGIONNODEDATATYPE_INFINITE   = 0x10000003
+)
+
+// UnitType
+const (
+	UNITTYPE_WORLD      = 0x00
+	UNITTYPE_DISPLAY    = 0x01
+	UNITTYPE_PIXEL      = 0x02
+	UNITTYPE_POINT      = 0x03
+	UNITTYPE_INCH       = 0x04
+	UNITTYPE_DOCUMENT   = 0x05
+	UNITTYPE_MILLIMETER = 0x06
+)
+
+// CombineMode
+const (
+	COMBINEMODE_REPLACE    = 0x00
+	COMBINEMODE_INTERSECT  = 0x01
+	COMBINEMODE_UNION      = 0x02
+	COMBINEMODE_XOR        = 0x03
+	COMBINEMODE_EXCLUDE    = 0x04
+	COMBINEMODE_COMPLEMENT = 0x05
+)
+
+// flags of EMF+ records
+const (
+	// EMF+ header: metafile contains both EMF+ and EMF records
+	PLUSFLAG_DUAL = 0x0001
+	// object definition continues in the next object record
+	PLUSFLAG_CONTINUE = 0x8000
+	// brush is specified as a color instead of object
+	PLUSFLAG_SOLIDCOLOR = 0x8000
+	// coordinates are 16-bit integers
+	PLUSFLAG_COMPRESSED = 0x4000
+	// transformation is applied after world transformation
+	PLUSFLAG_APPEND = 0x2000
+	// lines are closed into polygon
+	PLUSFLAG_CLOSED = 0x2000
+	// closed curve is filled using winding fill rule
+	PLUSFLAG_WINDING = 0x2000
+	// points are relative to the previous point
+	PLUSFLAG_RELATIVE = 0x0800
+)
+
+// flags of path object
+const (
+	PATHPOINTFLAG_COMPRESSED = 0x4000
+	PATHPOINTFLAG_RLE        = 0x1000
+	PATHPOINTFLAG_RELATIVE   = 0x0800
+)
+
+// HatchStyle
+const (
+	HATCHSTYLE_HORIZONTAL       = 0x00
+	HATCHSTYLE_VERTICAL         = 0x01
+	HATCHSTYLE_FORWARDDIAGONAL  = 0x02
+	HATCHSTYLE_BACKWARDDIAGONAL = 0x03
+	HATCHSTYLE_LARGEGRID        = 0x04
+	HATCHSTYLE_DIAGONALCROSS    = 0x05
+)
+
+// LineStyle
+const (
+	LINESTYLE_SOLID      = 0x00
+	LINESTYLE_DASH       = 0x01
+	LINESTYLE_DOT        = 0x02
+	LINESTYLE_DASHDOT    = 0x03
+	LINESTYLE_DASHDOTDOT = 0x04
+	LINESTYLE_CUSTOM     = 0x05
+)
+
+// LineCapType
+const (
+	LINECAPTYPE_FLAT     = 0x00
+	LINECAPTYPE_SQUARE   = 0x01
+	LINECAPTYPE_ROUND    = 0x02
+	LINECAPTYPE_TRIANGLE = 0x03
+)
+
+// LineJoinType
+const (
+	LINEJOINTYPE_MITER        = 0x00
+	LINEJOINTYPE_BEVEL        = 0x01
+	LINEJOINTYPE_ROUND        = 0x02
+	LINEJOINTYPE_MITERCLIPPED = 0x03
+)
+
+// WrapMode
+const (
+	WRAPMODE_TILE       = 0x00
+	WRAPMODE_TILEFLIPX  = 0x01
+	WRAPMODE_TILEFLIPY  = 0x02
+	WRAPMODE_TILEFLIPXY = 0x03
+	WRAPMODE_CLAMP      = 0x04
+)
+
+// PixelFormat
+const (
+	PIXELFORMAT_24BPPRGB   = 0x00021808
+	PIXELFORMAT_32BPPRGB   = 0x00022009
+	PIXELFORMAT_32BPPARGB  = 0x0026200A
+	PIXELFORMAT_32BPPPARGB = 0x000E200B
+)
@@ -2,9 +2,13 @@ package emf
 
 import (
 	"bytes"
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"os"
 
+	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
 )
 
@@ -12,11 +16,38 @@ type EmfFile struct {
 	Header  *HeaderRecord
 	Records []Recorder
 	EOF     *EOFRecord
+	// palette entries from EMR_EOF record
+	Palette []LogPaletteEntry
+	// EMF+ header and records embedded in comment records,
+	// continued objects are joined into a single object record
+	PlusHeader  *PlusHeaderRecord
+	PlusRecords []PlusRecorder
+	// ColorMatch converts color from the color space selected into device
+	// context to sRGB when color management is enabled by EMR_SETICMMODE,
+	// it's applied to colors of pens, brushes and text, to color tables
+	// and pixels of bitmaps, colors are not converted when it's nil
+	ColorMatch func(c color.RGBA, cs *LogColorSpace) color.RGBA
+	// Mode selects records drawn by Draw
+	Mode DrawMode
 }
 
+// DrawMode selects which records of the file are drawn
+type DrawMode int
+
+const (
+	// EMF+ records are drawn when the file has them, EMF records
+	// replace EMF+ records which are not supported
+	DrawPlusPreferred DrawMode = iota
+	// only EMF records are drawn
+	DrawEmfOnly
+	// only EMF+ records are drawn
+	DrawPlusOnly
+)
+
 func ReadFile(data []byte) (*EmfFile, error) {
 	reader := bytes.NewReader(data)
 	file := &EmfFile{}
+	objs := &plusObjects{}
 
 	for reader.Len() > 0 {
 		rec, err := readRecord(reader)
@@ -30,6 +61,18 @@ func ReadFile(data []byte) (*EmfFile, error) {
 			file.Header = rec
 		case *EOFRecord:
 			file.EOF = rec
+			file.Palette = rec.PalEntries
+		case *CommentRecord:
+			if rec.PlusRecords != nil {
+				rec.PlusRecords = objs.join(rec.PlusRecords)
+				for _, r := range rec.PlusRecords {
+					if h, ok := r.(*PlusHeaderRecord); ok && file.PlusHeader == nil {
+						file.PlusHeader = h
+					}
+				}
+				file.PlusRecords = append(file.PlusRecords, rec.PlusRecords...)
+			}
+			file.Records = append(file.Records, rec)
 		default:
 			file.Records = append(file.Records, rec)
 		}
@@ -41,27 +84,177 @@ func ReadFile(data []byte) (*EmfFile, error) {
 type context struct {
 	draw2dimg.GraphicContext
 	img     draw.Image
+	painter *clipPainter
 	objects map[uint32]interface{}
+	fonts   map[fontKey]loadedFont
+	// color conversion of color managed device context
+	colorMatch func(color.RGBA, *LogColorSpace) color.RGBA
+	// graphics state of EMF+ records
+	plus *plusContext
+	// records update device context without painting when it's set
+	discard bool
 
 	w, h int
+	// transformation from device units to image space
+	device draw2d.Matrix
 
 	wo, vo *PointL
 	we, ve *SizeL
 	mm     uint32
+
+	// device context state saved and restored along with graphic context
+	dcState
+	saved []dcState
+}
+
+type dcState struct {
+	stretchMode uint32
+	palette     *LogPalette
+	font        LogFont
+	textColor   color.RGBA
+	textAlign   uint32
+	bkColor     color.RGBA
+	bkMode      uint32
+	mapperFlags uint32
+	layout      uint32
+	icmMode     uint32
+	// color space or ICM profile, nil for default sRGB color space
+	colorSpace *LogColorSpace
+	// adjustment of bitmap colors, nil when colors are not adjusted
+	colorAdjustment *ColorAdjustment
+	// extra space distributed between break characters of text
+	breakExtra, breakCount int32
+	// clipping masks of clipping and meta regions in image space,
+	// nil when there is no clipping
+	clip, metaClip *image.Alpha
+	// current position in logical units
+	curX, curY float64
+	// path bracket is open
+	inPath bool
+	// path selected into device context by path bracket
+	path *draw2d.Path
+}
+
+func (ctx *context) save() {
+	ctx.Save()
+	ctx.saved = append(ctx.saved, ctx.dcState)
+}
+
+func (ctx *context) restore() {
+	ctx.Restore()
+	if n := len(ctx.saved); n > 0 {
+		ctx.dcState = ctx.saved[n-1]
+		ctx.saved = ctx.saved[:n-1]
+	}
+	ctx.updateClip()
 }
 
 func (f *EmfFile) initContext(w, h int) *context {
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
-	gc := draw2dimg.NewGraphicContext(img)
+	painter := newClipPainter(img)
+	gc := draw2dimg.NewGraphicContextWithPainter(img, painter)
 
 	return &context{
 		GraphicContext: *gc,
 		img:            img,
+		painter:        painter,
 		w:              w,
 		h:              h,
+		device:         draw2d.NewIdentityMatrix(),
 		mm:             MM_TEXT,
 		objects:        make(map[uint32]interface{}),
+		fonts:          make(map[fontKey]loadedFont),
+		colorMatch:     f.ColorMatch,
+		dcState: dcState{
+			stretchMode: STRETCH_ANDSCANS,
+			palette:     StockObjects[DEFAULT_PALETTE].(*LogPalette),
+			font:        StockObjects[SYSTEM_FONT].(LogFont),
+			textColor:   color.RGBA{0, 0, 0, 0xff},
+			textAlign:   TA_LEFT | TA_TOP | TA_NOUPDATECP,
+			bkColor:     color.RGBA{0xff, 0xff, 0xff, 0xff},
+			bkMode:      OPAQUE,
+			icmMode:     ICM_OFF,
+		},
+	}
+}
+
+// getObject returns stock object or object from the object table
+func (ctx *context) getObject(ih uint32) (interface{}, bool) {
+	object, ok := StockObjects[ih]
+	if !ok {
+		object, ok = ctx.objects[ih]
 	}
+	return object, ok
+}
+
+// getColor resolves color reference that could point
+// to the entry of selected logical palette
+func (ctx *context) getColor(c ColorRef) color.RGBA {
+	if c.Reserved == 0x01 {
+		idx := int(c.Green)<<8 | int(c.Red)
+		if idx < len(ctx.palette.PaletteEntries) {
+			return ctx.matchColor(ctx.palette.PaletteEntries[idx].GetColor())
+		}
+	}
+	return ctx.matchColor(c.GetColor())
+}
+
+// matchColor converts color from selected color space to sRGB
+// when color management is enabled
+func (ctx *context) matchColor(c color.RGBA) color.RGBA {
+	if !ctx.colorMatching() {
+		return c
+	}
+	return ctx.colorMatch(c, ctx.colorSpace)
+}
+
+// colorMatching reports whether colors are converted by color management
+func (ctx *context) colorMatching() bool {
+	return ctx.icmMode == ICM_ON && ctx.colorSpace != nil && ctx.colorMatch != nil
+}
+
+// brushColor returns color of the brush object, it's not ok for null brush
+func (ctx *context) brushColor(ih uint32) (color.Color, bool) {
+	object, ok := ctx.getObject(ih)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: object 0x%x not found\n", ih)
+		return nil, false
+	}
+
+	brush, ok := object.(LogBrushEx)
+	if !ok || brush.BrushStyle == BS_NULL {
+		return nil, false
+	}
+	return ctx.getColor(brush.Color), true
+}
+
+// paletteColors returns colors of selected logical palette entries
+func (ctx *context) paletteColors(indexes []uint16) []color.RGBA {
+	colors := make([]color.RGBA, len(indexes))
+	for i, idx := range indexes {
+		if int(idx) < len(ctx.palette.PaletteEntries) {
+			colors[i] = ctx.palette.PaletteEntries[idx].GetColor()
+		} else {
+			colors[i] = color.RGBA{0, 0, 0, 0xff}
+		}
+	}
+	return colors
+}
+
+// mirrorTransform returns transformation mirroring x axis of image space
+func (ctx *context) mirrorTransform() draw2d.Matrix {
+	return draw2d.Matrix{-1, 0, 0, 1, float64(ctx.w), 0}
+}
+
+// deviceTransform returns transformation from device units to image space,
+// device x axis goes from the right to the left in right-to-left layout
+func (ctx *context) deviceTransform() draw2d.Matrix {
+	if ctx.layout&LAYOUT_RTL == 0 {
+		return ctx.device
+	}
+	tr := ctx.mirrorTransform()
+	tr.Compose(ctx.device)
+	return tr
 }
 
 func (ctx context) applyTransformation() {
@@ -95,9 +288,15 @@ func (f *EmfFile) Draw() image.Image {
 	if bounds.Left != 0 || bounds.Top != 0 {
 		ctx.Translate(-float64(bounds.Left), -float64(bounds.Top))
 	}
+	ctx.device = ctx.GetMatrixTransform()
 
-	for _, rec := range f.Records {
-		rec.Draw(ctx)
+	switch {
+	case f.PlusHeader != nil && f.Mode != DrawEmfOnly:
+		f.drawPlus(ctx)
+	case f.Mode != DrawPlusOnly:
+		for _, rec := range f.Records {
+			rec.Draw(ctx)
+		}
 	}
 
 	return ctx.img
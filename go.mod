@@ -3,11 +3,9 @@ module github.com/pzinovkin/emf
 go 1.17
 
 require (
-	github.com/disintegration/imaging v1.6.2
 	github.com/llgcode/draw2d v0.0.0-20210904075650-80aa0a2a901d
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
+	golang.org/x/text v0.3.7
 )
 
-require (
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-)
+require github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
@@ -3,16 +3,50 @@ package emf
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"image/color"
 	"io"
-	"strings"
 	"unicode/utf16"
+
+	"github.com/llgcode/draw2d"
 )
 
 type LogPaletteEntry struct {
 	_, Blue, Green, Red uint8
 }
 
+func (e LogPaletteEntry) GetColor() color.RGBA {
+	return color.RGBA{e.Red, e.Green, e.Blue, 0xff}
+}
+
+type LogPalette struct {
+	Version        uint16
+	PaletteEntries []LogPaletteEntry
+}
+
+func readLogPalette(reader *bytes.Reader) (LogPalette, error) {
+	r := LogPalette{}
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return r, err
+	}
+
+	var n uint16
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return r, err
+	}
+
+	if int(n) > reader.Len()/4 {
+		return r, fmt.Errorf("invalid number of palette entries %d", n)
+	}
+
+	r.PaletteEntries = make([]LogPaletteEntry, n)
+	if err := binary.Read(reader, binary.LittleEndian, &r.PaletteEntries); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
 type LogPen struct {
 	PenStyle uint32
 	Width    PointL
@@ -78,10 +112,21 @@ type EmrText struct {
 	Rectangle    RectL
 	offDx        uint32
 	OutputString string
-	OutputDx     []uint32
+	// glyph indices of the font when ETO_GLYPH_INDEX option is set,
+	// OutputString is empty in this case
+	Glyphs []uint16
+	// distances between origins of adjacent character cells,
+	// vertical ones are present when ETO_PDY option is set
+	OutputDx, OutputDy []int32
+	// 8-bit characters of ANSI text, they are decoded
+	// according to character set of the selected font
+	ansi []byte
 }
 
-func readEmrText(reader *bytes.Reader, offset int) (EmrText, error) {
+// readEmrText reads text object, offset is the reader length at the start
+// of the record. Reader is left at the end of the object, so the text
+// objects of PolyTextOut records can be read one by one.
+func readEmrText(reader *bytes.Reader, offset int, ansi bool) (EmrText, error) {
 	r := EmrText{}
 	if err := binary.Read(reader, binary.LittleEndian, &r.Reference); err != nil {
 		return r, err
@@ -95,31 +140,95 @@ func readEmrText(reader *bytes.Reader, offset int) (EmrText, error) {
 	if err := binary.Read(reader, binary.LittleEndian, &r.Options); err != nil {
 		return r, err
 	}
-	if err := binary.Read(reader, binary.LittleEndian, &r.Rectangle); err != nil {
-		return r, err
+	if r.Options&ETO_NO_RECT == 0 {
+		if err := binary.Read(reader, binary.LittleEndian, &r.Rectangle); err != nil {
+			return r, err
+		}
 	}
 	if err := binary.Read(reader, binary.LittleEndian, &r.offDx); err != nil {
 		return r, err
 	}
+	end := reader.Len()
+
+	// characters and spacing are within the record
+	if r.Chars > uint32(offset) {
+		return r, fmt.Errorf("invalid number of characters %d", r.Chars)
+	}
 
 	// UndefinedSpace1
 	reader.Seek(int64(int(r.offString)-(offset-reader.Len())), io.SeekCurrent)
-	b := make([]uint16, r.Chars)
-	if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
-		return r, err
+	if ansi {
+		b := make([]byte, r.Chars)
+		if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
+			return r, err
+		}
+		r.setAnsi(b)
+	} else {
+		b := make([]uint16, r.Chars)
+		if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
+			return r, err
+		}
+		r.setWide(b)
 	}
-	r.OutputString = string(utf16.Decode(b))
 
 	// UndefinedSpace2
-	reader.Seek(int64(int(r.offDx)-(offset-reader.Len())), io.SeekCurrent)
-	r.OutputDx = make([]uint32, r.Chars)
-	if err := binary.Read(reader, binary.LittleEndian, &r.OutputDx); err != nil {
-		return r, err
+	if r.offDx != 0 {
+		reader.Seek(int64(int(r.offDx)-(offset-reader.Len())), io.SeekCurrent)
+		if r.Options&ETO_PDY != 0 {
+			// pairs of horizontal and vertical spacing
+			dxy := make([]int32, 2*r.Chars)
+			if err := binary.Read(reader, binary.LittleEndian, &dxy); err != nil {
+				return r, err
+			}
+			r.OutputDx = make([]int32, r.Chars)
+			r.OutputDy = make([]int32, r.Chars)
+			for i := range r.OutputDx {
+				r.OutputDx[i], r.OutputDy[i] = dxy[2*i], dxy[2*i+1]
+			}
+		} else {
+			r.OutputDx = make([]int32, r.Chars)
+			if err := binary.Read(reader, binary.LittleEndian, &r.OutputDx); err != nil {
+				return r, err
+			}
+		}
 	}
 
+	reader.Seek(int64(reader.Len()-end), io.SeekCurrent)
 	return r, nil
 }
 
+// setAnsi sets output string from 8-bit characters or glyph indices
+func (r *EmrText) setAnsi(b []byte) {
+	if r.Options&ETO_GLYPH_INDEX != 0 {
+		r.Glyphs = make([]uint16, len(b))
+		for i, g := range b {
+			r.Glyphs[i] = uint16(g)
+		}
+		return
+	}
+
+	r.ansi = b
+	r.OutputString = decodeString(b, ANSI_CHARSET)
+}
+
+// setWide sets output string from UTF-16 characters or glyph indices
+func (r *EmrText) setWide(b []uint16) {
+	if r.Options&ETO_GLYPH_INDEX != 0 {
+		r.Glyphs = b
+		return
+	}
+
+	r.OutputString = string(utf16.Decode(b))
+}
+
+// text returns output string decoded with the character set
+func (r EmrText) text(charset uint8) string {
+	if r.ansi != nil {
+		return decodeString(r.ansi, charset)
+	}
+	return r.OutputString
+}
+
 type LogFont struct {
 	Height, Width                        int32
 	Escapement, Orientation, Weight      int32
@@ -128,8 +237,22 @@ type LogFont struct {
 	Quality                              uint8
 	PitchAndFamily                       int8
 	Facename                             string
+
+	// LogFontExDv and LogFontPanose data
+	FullName, Style string
+	// LogFontExDv only
+	Script       string
+	DesignVector []int32
+	// LogFontPanose only
+	Panose Panose
 }
 
+const (
+	logFontSize           = 92
+	logFontPanoseSize     = 320
+	designVectorSignature = 0x08007664
+)
+
 func readLogFont(reader *bytes.Reader) (LogFont, error) {
 	r := LogFont{}
 	if err := binary.Read(reader, binary.LittleEndian, &r.Height); err != nil {
@@ -172,18 +295,205 @@ func readLogFont(reader *bytes.Reader) (LogFont, error) {
 		return r, err
 	}
 
-	b := make([]uint16, 32)
+	var err error
+	r.Facename, err = readWideString(reader, 32)
+	if err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// readLogFontW reads LogFont object extended with LogFontExDv or
+// LogFontPanose data depending on the size of the object
+func readLogFontW(reader *bytes.Reader, size int) (LogFont, error) {
+	r, err := readLogFont(reader)
+	if err != nil || size < logFontPanoseSize {
+		return r, err
+	}
+
+	if r.FullName, err = readWideString(reader, 64); err != nil {
+		return r, err
+	}
+	if r.Style, err = readWideString(reader, 32); err != nil {
+		return r, err
+	}
+
+	if size == logFontPanoseSize {
+		// Version, StyleSize, Match, Reserved, VendorId, Culture
+		reader.Seek(24, io.SeekCurrent)
+		if err := binary.Read(reader, binary.LittleEndian, &r.Panose); err != nil {
+			return r, err
+		}
+		// Padding
+		reader.Seek(2, io.SeekCurrent)
+		return r, nil
+	}
+
+	if r.Script, err = readWideString(reader, 32); err != nil {
+		return r, err
+	}
+
+	var signature, numAxes uint32
+	if err := binary.Read(reader, binary.LittleEndian, &signature); err != nil {
+		return r, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &numAxes); err != nil {
+		return r, err
+	}
+	if signature != designVectorSignature || numAxes > 16 {
+		// design vector is not present
+		return r, nil
+	}
+
+	r.DesignVector = make([]int32, numAxes)
+	if err := binary.Read(reader, binary.LittleEndian, &r.DesignVector); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// readWideString reads null-terminated UTF-16 string of fixed length
+func readWideString(reader *bytes.Reader, n int) (string, error) {
+	b := make([]uint16, n)
 	if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
+		return "", err
+	}
+	for i, c := range b {
+		if c == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(b)), nil
+}
+
+// LogColorSpace is a logical color space object created by color space
+// records or selected by ICM profile records
+type LogColorSpace struct {
+	Signature, Version, Size        uint32
+	ColorSpaceType, Intent          int32
+	Endpoints                       [9]int32
+	GammaRed, GammaGreen, GammaBlue uint32
+	Filename                        string
+	// ICC profile data embedded into the metafile
+	Profile []byte
+}
+
+// readLogColorSpace reads LogColorSpace or LogColorSpaceW object
+func readLogColorSpace(reader *bytes.Reader, wide bool) (LogColorSpace, error) {
+	r := LogColorSpace{}
+
+	var lcs struct {
+		Signature, Version, Size        uint32
+		ColorSpaceType, Intent          int32
+		Endpoints                       [9]int32
+		GammaRed, GammaGreen, GammaBlue uint32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &lcs); err != nil {
+		return r, err
+	}
+	r.Signature, r.Version, r.Size = lcs.Signature, lcs.Version, lcs.Size
+	r.ColorSpaceType, r.Intent, r.Endpoints = lcs.ColorSpaceType, lcs.Intent, lcs.Endpoints
+	r.GammaRed, r.GammaGreen, r.GammaBlue = lcs.GammaRed, lcs.GammaGreen, lcs.GammaBlue
+
+	var err error
+	if wide {
+		r.Filename, err = readWideString(reader, maxPath)
+	} else {
+		r.Filename, err = readAnsiString(reader, maxPath)
+	}
+	return r, err
+}
+
+// readAnsiString reads null-terminated ANSI string of fixed length
+func readAnsiString(reader *bytes.Reader, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return decodeString(b, ANSI_CHARSET), nil
+}
+
+// maximum length of file path in LogColorSpace object
+const maxPath = 260
+
+// ColorAdjustment specifies adjustment of bitmap colors in HALFTONE
+// stretch mode, gamma and reference values are in 1/10000 units
+type ColorAdjustment struct {
+	Size, Values                    uint16
+	IlluminantIndex                 uint16
+	RedGamma, GreenGamma, BlueGamma uint16
+	ReferenceBlack, ReferenceWhite  uint16
+	Contrast, Brightness            int16
+	Colorfulness, RedGreenTint      int16
+}
+
+type Panose struct {
+	FamilyType, SerifStyle, Weight, Proportion, Contrast uint8
+	StrokeVariation, ArmStyle, Letterform, Midline       uint8
+	XHeight                                              uint8
+}
+
+type RegionDataHeader struct {
+	Size, Type, CountRects, RgnSize uint32
+	Bounds                          RectL
+}
+
+// Region is a set of non-overlapping rectangles
+type Region struct {
+	Bounds RectL
+	Rects  []RectL
+}
+
+func readRegion(reader *bytes.Reader) (Region, error) {
+	r := Region{}
+
+	var h RegionDataHeader
+	if err := binary.Read(reader, binary.LittleEndian, &h); err != nil {
+		return r, err
+	}
+	if h.Size > 32 {
+		reader.Seek(int64(h.Size)-32, io.SeekCurrent)
+	}
+
+	// rectangles fill the region data
+	if h.CountRects > h.RgnSize/16 || int(h.CountRects) > reader.Len()/16 {
+		return r, fmt.Errorf("invalid number of rectangles %d", h.CountRects)
+	}
+
+	r.Bounds = h.Bounds
+	r.Rects = make([]RectL, h.CountRects)
+	if err := binary.Read(reader, binary.LittleEndian, &r.Rects); err != nil {
 		return r, err
 	}
-	r.Facename = strings.TrimRight(string(utf16.Decode(b)), "\x00")
 
 	return r, nil
 }
 
+// path returns path of region rectangles
+func (r Region) path() *draw2d.Path {
+	p := &draw2d.Path{}
+	for _, rc := range r.Rects {
+		x1, y1, x2, y2 := float64(rc.Left), float64(rc.Top), float64(rc.Right), float64(rc.Bottom)
+		p.MoveTo(x1, y1)
+		p.LineTo(x2, y1)
+		p.LineTo(x2, y2)
+		p.LineTo(x1, y2)
+		p.Close()
+	}
+	return p
+}
+
 // MS-WMF types
 type ColorRef struct {
-	Red, Green, Blue, _ uint8
+	// Reserved is 0x01 for PALETTEINDEX colors, then Red and Green
+	// hold palette entry index
+	Red, Green, Blue, Reserved uint8
 }
 
 func (c ColorRef) GetColor() color.RGBA {
@@ -227,4 +537,233 @@ type BitmapInfoHeader struct {
 	ColorUsed, ColorImportant    uint32
 }
 
+// BitmapInfo is a bitmap header of any supported version (BITMAPCOREHEADER,
+// BITMAPINFOHEADER, BITMAPV4HEADER and BITMAPV5HEADER) with color masks
+// and color table.
+type BitmapInfo struct {
+	BitmapInfoHeader
+	// used only for BI_BITFIELDS and BI_ALPHABITFIELDS compression
+	RedMask, GreenMask, BlueMask, AlphaMask uint32
+	// BITMAPV4HEADER
+	ColorSpaceType                  uint32
+	Endpoints                       [9]int32
+	GammaRed, GammaGreen, GammaBlue uint32
+	// BITMAPV5HEADER
+	Intent, ProfileData, ProfileSize uint32
+
+	Colors []color.RGBA
+	// color table of DIB_PAL_COLORS bitmaps holds indexes
+	// into the current logical palette
+	Indexes []uint16
+}
+
+// header sizes of bitmap header versions
+const (
+	bitmapCoreHeaderSize = 12
+	bitmapInfoHeaderSize = 40
+	bitmapV4HeaderSize   = 108
+	bitmapV5HeaderSize   = 124
+)
+
+func readBitmapInfo(reader *bytes.Reader, usage uint32) (BitmapInfo, error) {
+	r := BitmapInfo{}
+	if err := binary.Read(reader, binary.LittleEndian, &r.HeaderSize); err != nil {
+		return r, err
+	}
+
+	if r.HeaderSize == bitmapCoreHeaderSize {
+		var core struct {
+			Width, Height, Planes, BitCount uint16
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &core); err != nil {
+			return r, err
+		}
+		r.Width, r.Height = int32(core.Width), int32(core.Height)
+		r.Planes, r.BitCount = core.Planes, core.BitCount
+		r.Compression = BI_RGB
+	} else {
+		reader.Seek(-4, io.SeekCurrent)
+		if err := binary.Read(reader, binary.LittleEndian, &r.BitmapInfoHeader); err != nil {
+			return r, err
+		}
+	}
+
+	// BITMAPV4HEADER and BITMAPV5HEADER contain masks right after
+	// BITMAPINFOHEADER fields, otherwise masks follow the header
+	if r.HeaderSize > bitmapInfoHeaderSize || r.bitfields() {
+		if err := binary.Read(reader, binary.LittleEndian, &r.RedMask); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.GreenMask); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.BlueMask); err != nil {
+			return r, err
+		}
+	}
+
+	if r.HeaderSize > 52 || r.HeaderSize == bitmapInfoHeaderSize && r.Compression == BI_ALPHABITFIELDS {
+		if err := binary.Read(reader, binary.LittleEndian, &r.AlphaMask); err != nil {
+			return r, err
+		}
+	}
+
+	if r.HeaderSize >= bitmapV4HeaderSize {
+		if err := binary.Read(reader, binary.LittleEndian, &r.ColorSpaceType); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.Endpoints); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.GammaRed); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.GammaGreen); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.GammaBlue); err != nil {
+			return r, err
+		}
+	}
+
+	if r.HeaderSize >= bitmapV5HeaderSize {
+		if err := binary.Read(reader, binary.LittleEndian, &r.Intent); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.ProfileData); err != nil {
+			return r, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &r.ProfileSize); err != nil {
+			return r, err
+		}
+		// Reserved
+		reader.Seek(4, io.SeekCurrent)
+	}
+
+	// skip the rest of unknown header versions
+	if read := r.headerSize(); r.HeaderSize > read {
+		reader.Seek(int64(r.HeaderSize-read), io.SeekCurrent)
+	}
+
+	n := r.ColorUsed
+	if r.BitCount > 0 && r.BitCount <= BI_BITCOUNT_3 {
+		if n == 0 || n > 1<<r.BitCount {
+			n = 1 << r.BitCount
+		}
+	}
+
+	// color table is made of RGBTRIPLE for BITMAPCOREHEADER
+	// and RGBQUAD for others
+	entry := make([]uint8, 4)
+	if r.HeaderSize == bitmapCoreHeaderSize {
+		entry = entry[:3]
+	}
+
+	// color table can't go beyond the data
+	size := len(entry)
+	if usage == DIB_PAL_COLORS {
+		size = 2
+	}
+	if max := uint32(reader.Len() / size); n > max {
+		n = max
+	}
+
+	if usage == DIB_PAL_COLORS {
+		r.Indexes = make([]uint16, n)
+		if err := binary.Read(reader, binary.LittleEndian, &r.Indexes); err != nil {
+			return r, err
+		}
+		return r, nil
+	}
+
+	r.Colors = make([]color.RGBA, n)
+	for i := range r.Colors {
+		if _, err := io.ReadFull(reader, entry); err != nil {
+			return r, err
+		}
+		r.Colors[i] = color.RGBA{entry[2], entry[1], entry[0], 0xff}
+	}
+
+	return r, nil
+}
+
+// bitfields reports whether pixels are decoded with color masks of the header
+func (r BitmapInfo) bitfields() bool {
+	return r.Compression == BI_BITFIELDS || r.Compression == BI_ALPHABITFIELDS
+}
+
+// headerSize returns amount of header bytes that is known to the reader
+func (r BitmapInfo) headerSize() uint32 {
+	switch {
+	case r.HeaderSize >= bitmapV5HeaderSize:
+		return bitmapV5HeaderSize
+	case r.HeaderSize >= bitmapV4HeaderSize:
+		return bitmapV4HeaderSize
+	case r.HeaderSize > 52:
+		return 56
+	case r.HeaderSize > bitmapInfoHeaderSize:
+		return 52
+	}
+	return r.HeaderSize
+}
+
+// size returns amount of bytes occupied by header, masks and color table
+func (r BitmapInfo) size() uint32 {
+	size := r.HeaderSize
+	if r.HeaderSize == bitmapInfoHeaderSize && r.Compression == BI_BITFIELDS {
+		size += 12
+	}
+	if r.HeaderSize == bitmapInfoHeaderSize && r.Compression == BI_ALPHABITFIELDS {
+		size += 16
+	}
+	if r.HeaderSize == bitmapCoreHeaderSize {
+		return size + uint32(len(r.Colors))*3
+	}
+	return size + uint32(len(r.Colors))*4 + uint32(len(r.Indexes))*2
+}
+
+// TopDown reports whether bitmap rows are stored from top to bottom
+func (r BitmapInfo) TopDown() bool {
+	return r.Height < 0
+}
+
+// palette returns color table for indexed bitmaps padded to
+// the number of colors addressable with bit count
+func (r BitmapInfo) palette(colors []color.RGBA) color.Palette {
+	n := 1 << r.BitCount
+	p := make(color.Palette, n)
+
+	if len(colors) == 0 {
+		// there is no color table, use grayscale
+		for i := range p {
+			v := uint8(i * 0xff / (n - 1))
+			p[i] = color.RGBA{v, v, v, 0xff}
+		}
+		return p
+	}
+
+	for i := range p {
+		if i < len(colors) {
+			p[i] = colors[i]
+		} else {
+			p[i] = color.RGBA{0, 0, 0, 0xff}
+		}
+	}
+	return p
+}
+
+// masks returns color masks for 16 and 32 bits per pixel bitmaps
+func (r BitmapInfo) masks() (red, green, blue, alpha uint32) {
+	if r.bitfields() {
+		return r.RedMask, r.GreenMask, r.BlueMask, r.AlphaMask
+	}
+
+	if r.BitCount == BI_BITCOUNT_4 {
+		// 5-5-5
+		return 0x7C00, 0x03E0, 0x001F, 0
+	}
+	// BGRX
+	return 0x00FF0000, 0x0000FF00, 0x000000FF, 0
+}
+
 type DibHeaderInfo struct{}
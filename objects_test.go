@@ -0,0 +1,338 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// encode returns values encoded in little-endian byte order
+func encode(vals ...interface{}) []byte {
+	b := &bytes.Buffer{}
+	for _, v := range vals {
+		binary.Write(b, binary.LittleEndian, v)
+	}
+	return b.Bytes()
+}
+
+func TestReadEmrText(t *testing.T) {
+	// text objects start after 8 bytes of the record,
+	// offsets of string and spacing are from the record start
+	tests := []struct {
+		name       string
+		options    uint32
+		offString  uint32
+		offDx      uint32
+		ansi       bool
+		data       []interface{}
+		text       string
+		dx, dy     []int32
+		objectSize int
+	}{
+		{
+			name:       "wide string",
+			offString:  48,
+			data:       []interface{}{[]uint16{'h', 'i'}},
+			text:       "hi",
+			objectSize: 40,
+		},
+		{
+			name:       "ansi string with spacing",
+			offString:  48,
+			offDx:      52,
+			ansi:       true,
+			data:       []interface{}{[]byte{'h', 'i', 0, 0}, []int32{5, 6}},
+			text:       "hi",
+			dx:         []int32{5, 6},
+			objectSize: 40,
+		},
+		{
+			name:       "ETO_PDY spacing pairs",
+			options:    ETO_PDY,
+			offString:  48,
+			offDx:      52,
+			data:       []interface{}{[]uint16{'h', 'i'}, []int32{5, -1, 6, -2}},
+			text:       "hi",
+			dx:         []int32{5, 6},
+			dy:         []int32{-1, -2},
+			objectSize: 40,
+		},
+		{
+			name:       "no rectangle",
+			options:    ETO_NO_RECT | ETO_PDY,
+			offString:  32,
+			offDx:      36,
+			data:       []interface{}{[]uint16{'h', 'i'}, []int32{5, -1, 6, -2}},
+			text:       "hi",
+			dx:         []int32{5, 6},
+			dy:         []int32{-1, -2},
+			objectSize: 24,
+		},
+		{
+			name:       "spacing before string with undefined space",
+			options:    ETO_PDY,
+			offString:  68,
+			offDx:      48,
+			data:       []interface{}{[]int32{5, -1, 6, -2}, uint32(0xdeadbeef), []uint16{'h', 'i'}},
+			text:       "hi",
+			dx:         []int32{5, 6},
+			dy:         []int32{-1, -2},
+			objectSize: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		object := []interface{}{PointL{10, 20}, uint32(2), tt.offString, tt.options}
+		if tt.options&ETO_NO_RECT == 0 {
+			object = append(object, RectL{1, 2, 3, 4})
+		}
+		object = append(object, tt.offDx)
+
+		data := append(make([]byte, 8), encode(object...)...)
+		data = append(data, encode(tt.data...)...)
+		reader := bytes.NewReader(data)
+		reader.Seek(8, io.SeekStart)
+
+		text, err := readEmrText(reader, len(data), tt.ansi)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if text.Reference != (PointL{10, 20}) || text.Chars != 2 || text.Options != tt.options {
+			t.Errorf("%s: got %+v", tt.name, text)
+		}
+		if text.OutputString != tt.text {
+			t.Errorf("%s: got string %q, want %q", tt.name, text.OutputString, tt.text)
+		}
+		if !reflect.DeepEqual(text.OutputDx, tt.dx) {
+			t.Errorf("%s: got dx %v, want %v", tt.name, text.OutputDx, tt.dx)
+		}
+		if !reflect.DeepEqual(text.OutputDy, tt.dy) {
+			t.Errorf("%s: got dy %v, want %v", tt.name, text.OutputDy, tt.dy)
+		}
+		// reader is left at the end of the object
+		if n := len(data) - 8 - reader.Len(); n != tt.objectSize {
+			t.Errorf("%s: read %d bytes, want %d", tt.name, n, tt.objectSize)
+		}
+	}
+}
+
+func TestReadBitmapInfo(t *testing.T) {
+	header := func(size uint32, bitCount uint16, compression, colorUsed uint32) BitmapInfoHeader {
+		return BitmapInfoHeader{
+			HeaderSize: size, Width: 2, Height: -2, Planes: 1, BitCount: bitCount,
+			Compression: compression, ColorUsed: colorUsed,
+		}
+	}
+	masks := []uint32{0xff0000, 0xff00, 0xff}
+
+	tests := []struct {
+		name    string
+		usage   uint32
+		data    []interface{}
+		want    BitmapInfo
+		size    uint32
+		invalid bool
+	}{
+		{
+			name: "core header with color table",
+			data: []interface{}{uint32(12), []uint16{2, 3, 1, 1}, []byte{1, 2, 3, 4, 5, 6}},
+			want: BitmapInfo{
+				BitmapInfoHeader: BitmapInfoHeader{HeaderSize: 12, Width: 2, Height: 3, Planes: 1, BitCount: 1},
+				Colors:           []color.RGBA{{3, 2, 1, 0xff}, {6, 5, 4, 0xff}},
+			},
+			size: 18,
+		},
+		{
+			name:  "palette indexes",
+			usage: DIB_PAL_COLORS,
+			data:  []interface{}{header(40, 1, BI_RGB, 0), []uint16{7, 8}},
+			want:  BitmapInfo{BitmapInfoHeader: header(40, 1, BI_RGB, 0), Indexes: []uint16{7, 8}},
+			size:  44,
+		},
+		{
+			name: "bit fields",
+			data: []interface{}{header(40, 32, BI_BITFIELDS, 0), masks},
+			want: BitmapInfo{
+				BitmapInfoHeader: header(40, 32, BI_BITFIELDS, 0),
+				RedMask:          0xff0000,
+				GreenMask:        0xff00,
+				BlueMask:         0xff,
+				Colors:           []color.RGBA{},
+			},
+			size: 52,
+		},
+		{
+			name: "alpha bit fields",
+			data: []interface{}{header(40, 32, BI_ALPHABITFIELDS, 0), masks, uint32(0xff000000)},
+			want: BitmapInfo{
+				BitmapInfoHeader: header(40, 32, BI_ALPHABITFIELDS, 0),
+				RedMask:          0xff0000,
+				GreenMask:        0xff00,
+				BlueMask:         0xff,
+				AlphaMask:        0xff000000,
+				Colors:           []color.RGBA{},
+			},
+			size: 56,
+		},
+		{
+			name: "masks of V4 header",
+			data: []interface{}{header(108, 32, BI_BITFIELDS, 0), masks, uint32(0xff000000),
+				uint32(LCS_sRGB), make([]byte, 48)},
+			want: BitmapInfo{
+				BitmapInfoHeader: header(108, 32, BI_BITFIELDS, 0),
+				RedMask:          0xff0000,
+				GreenMask:        0xff00,
+				BlueMask:         0xff,
+				AlphaMask:        0xff000000,
+				ColorSpaceType:   LCS_sRGB,
+				Colors:           []color.RGBA{},
+			},
+			size: 108,
+		},
+		{
+			name: "color table is limited by data",
+			data: []interface{}{header(40, 24, BI_RGB, 0x7fffffff), []byte{1, 2, 3, 0}},
+			want: BitmapInfo{
+				BitmapInfoHeader: header(40, 24, BI_RGB, 0x7fffffff),
+				Colors:           []color.RGBA{{3, 2, 1, 0xff}},
+			},
+			size: 44,
+		},
+		{
+			name:    "truncated masks",
+			data:    []interface{}{header(40, 16, BI_BITFIELDS, 0), uint32(0x7c00)},
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		bi, err := readBitmapInfo(bytes.NewReader(encode(tt.data...)), tt.usage)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(bi, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, bi, tt.want)
+		}
+		if bi.size() != tt.size {
+			t.Errorf("%s: got size %d, want %d", tt.name, bi.size(), tt.size)
+		}
+	}
+}
+
+func TestReadRegion(t *testing.T) {
+	// RDH_RECTANGLES region data type
+	const rdhRectangles = 1
+	bounds := RectL{0, 0, 20, 10}
+	rects := []RectL{{0, 0, 20, 5}, {5, 5, 10, 10}}
+
+	tests := []struct {
+		name    string
+		header  RegionDataHeader
+		data    []interface{}
+		want    []RectL
+		invalid bool
+	}{
+		{
+			name:   "rectangles",
+			header: RegionDataHeader{32, rdhRectangles, 2, 32, bounds},
+			data:   []interface{}{rects},
+			want:   rects,
+		},
+		{
+			name:   "larger header",
+			header: RegionDataHeader{36, rdhRectangles, 1, 16, bounds},
+			data:   []interface{}{uint32(0), rects[0]},
+			want:   rects[:1],
+		},
+		{
+			name:   "empty region",
+			header: RegionDataHeader{32, rdhRectangles, 0, 0, bounds},
+			want:   []RectL{},
+		},
+		{
+			name:    "count exceeds region size",
+			header:  RegionDataHeader{32, rdhRectangles, 2, 16, bounds},
+			data:    []interface{}{rects},
+			invalid: true,
+		},
+		{
+			name:    "count exceeds data",
+			header:  RegionDataHeader{32, rdhRectangles, 0x10000000, 0xffffffff, bounds},
+			data:    []interface{}{rects},
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		data := encode(append([]interface{}{tt.header}, tt.data...)...)
+		rgn, err := readRegion(bytes.NewReader(data))
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rgn.Bounds != bounds || !reflect.DeepEqual(rgn.Rects, tt.want) {
+			t.Errorf("%s: got %+v, want %v", tt.name, rgn, tt.want)
+		}
+	}
+}
+
+func TestReadLogPalette(t *testing.T) {
+	entries := []LogPaletteEntry{{Red: 1, Green: 2, Blue: 3}, {Red: 4, Green: 5, Blue: 6}}
+
+	tests := []struct {
+		name    string
+		data    []interface{}
+		want    []LogPaletteEntry
+		invalid bool
+	}{
+		{
+			name: "entries",
+			data: []interface{}{uint16(0x300), uint16(2), entries},
+			want: entries,
+		},
+		{
+			name: "no entries",
+			data: []interface{}{uint16(0x300), uint16(0)},
+			want: []LogPaletteEntry{},
+		},
+		{
+			name:    "count exceeds data",
+			data:    []interface{}{uint16(0x300), uint16(0xffff), entries},
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		pal, err := readLogPalette(bytes.NewReader(encode(tt.data...)))
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if pal.Version != 0x300 || !reflect.DeepEqual(pal.PaletteEntries, tt.want) {
+			t.Errorf("%s: got %+v, want %v", tt.name, pal, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package emf
+
+import (
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dbase"
+)
+
+// Path bracket is opened with BeginPath record, shape records add their
+// geometry to the current path of graphic context without drawing it. When
+// bracket is closed with EndPath record the path is selected into device
+// context and can be used by path drawing and clipping records.
+
+// pathBuilder collects flattened path
+type pathBuilder struct {
+	*draw2d.Path
+	// figures are closed when they end
+	closeFigures bool
+}
+
+func (p pathBuilder) LineJoin() {}
+
+func (p pathBuilder) End() {
+	if p.closeFigures && !p.IsEmpty() {
+		p.Close()
+	}
+}
+
+// beginPath opens path bracket and discards current path
+func (ctx *context) beginPath() {
+	ctx.BeginPath()
+	ctx.inPath = true
+	ctx.path = nil
+}
+
+// endPath closes path bracket and selects its path into device context
+func (ctx *context) endPath() {
+	if !ctx.inPath {
+		return
+	}
+	ctx.path = ctx.Current.Path.Copy()
+	ctx.BeginPath()
+	ctx.inPath = false
+}
+
+// abortPath closes path bracket and discards any path
+func (ctx *context) abortPath() {
+	ctx.BeginPath()
+	ctx.inPath = false
+	ctx.path = nil
+}
+
+// moveTo sets current position, inside of path bracket it starts a new figure
+func (ctx *context) moveTo(x, y float64) {
+	ctx.curX, ctx.curY = x, y
+	if ctx.inPath {
+		ctx.MoveTo(x, y)
+	}
+}
+
+// startFigure starts segments drawn from current position. Outside of path
+// bracket they form a new path which is stroked by endFigure.
+func (ctx *context) startFigure() {
+	if !ctx.inPath {
+		ctx.BeginPath()
+	}
+	if x, y := ctx.LastPoint(); ctx.IsEmpty() || x != ctx.curX || y != ctx.curY {
+		ctx.MoveTo(ctx.curX, ctx.curY)
+	}
+}
+
+// endFigure moves current position to the end of segments
+// and strokes them unless it's a part of path bracket
+func (ctx *context) endFigure() {
+	ctx.curX, ctx.curY = ctx.LastPoint()
+	ctx.strokeShape()
+}
+
+// polylineTo adds lines from current position through the points
+func (ctx *context) polylineTo(points []PointL) {
+	ctx.startFigure()
+	for _, p := range points {
+		ctx.LineTo(float64(p.X), float64(p.Y))
+	}
+	ctx.endFigure()
+}
+
+// polybezierTo adds Bezier curves from current position, each curve is
+// specified by two control points and the end point
+func (ctx *context) polybezierTo(points []PointL) {
+	ctx.startFigure()
+	for i := 0; i+2 < len(points); i = i + 3 {
+		ctx.CubicCurveTo(
+			float64(points[i].X), float64(points[i].Y),
+			float64(points[i+1].X), float64(points[i+1].Y),
+			float64(points[i+2].X), float64(points[i+2].Y),
+		)
+	}
+	ctx.endFigure()
+}
+
+// pointsL converts 16-bit points to 32-bit ones
+func pointsL(points []PointS) []PointL {
+	l := make([]PointL, len(points))
+	for i, p := range points {
+		l[i] = PointL{X: int32(p.X), Y: int32(p.Y)}
+	}
+	return l
+}
+
+// arc adds elliptic arc bounded by the box to current path,
+// arc goes from the radial through start point to the radial through end point
+func (ctx *context) arc(box RectL, start, end PointL) {
+	center := box.Center()
+	rx := (float64(box.Right) - float64(box.Left) - 1) / 2
+	ry := (float64(box.Bottom) - float64(box.Top) - 1) / 2
+	// angles are specified in radians
+	sa := math.Atan2(float64(start.Y-center.Y), float64(start.X-center.X))
+	ea := math.Atan2(float64(end.Y-center.Y), float64(end.X-center.X)) - sa
+
+	ctx.ArcTo(float64(center.X), float64(center.Y), rx, ry, sa, ea)
+}
+
+// fillShape fills and strokes current path unless it's a part of path bracket
+func (ctx *context) fillShape() {
+	if !ctx.inPath {
+		ctx.FillStroke()
+	}
+}
+
+// strokeShape strokes current path unless it's a part of path bracket
+func (ctx *context) strokeShape() {
+	if !ctx.inPath {
+		ctx.Stroke()
+	}
+}
+
+// flattenPath replaces curves of selected path with lines
+func (ctx *context) flattenPath() {
+	if ctx.path == nil {
+		return
+	}
+
+	p := pathBuilder{Path: &draw2d.Path{}}
+	draw2dbase.Flatten(ctx.path, p, ctx.GetMatrixTransform().GetScale())
+	ctx.path = p.Path
+}
+
+// widenPath replaces selected path with the outline of its stroke
+// by the current pen with its end caps and joins
+func (ctx *context) widenPath() {
+	if ctx.path == nil {
+		return
+	}
+
+	scale := ctx.GetMatrixTransform().GetScale()
+	width := ctx.Current.LineWidth
+	if width*scale < 1 {
+		// cosmetic pen is one pixel wide
+		width = 1 / scale
+	}
+
+	p := pathBuilder{Path: &draw2d.Path{}, closeFigures: true}
+	stroker := draw2dbase.NewLineStroker(ctx.Current.Cap, ctx.Current.Join, p)
+	stroker.HalfLineWidth = width / 2
+	draw2dbase.Flatten(ctx.path, stroker, scale)
+	ctx.path = p.Path
+}
+
+// closeFigures returns copy of the path with all open figures closed
+func closeFigures(p *draw2d.Path) *draw2d.Path {
+	c := &draw2d.Path{Points: append([]float64(nil), p.Points...)}
+	open := false
+	for _, cmp := range p.Components {
+		switch cmp {
+		case draw2d.MoveToCmp:
+			if open {
+				c.Components = append(c.Components, draw2d.CloseCmp)
+			}
+			open = false
+		case draw2d.CloseCmp:
+			open = false
+		default:
+			open = true
+		}
+		c.Components = append(c.Components, cmp)
+	}
+	if open {
+		c.Components = append(c.Components, draw2d.CloseCmp)
+	}
+	return c
+}
+
+// fillPath fills selected path and discards it
+func (ctx *context) fillPath() {
+	if ctx.path == nil {
+		return
+	}
+	ctx.Fill(closeFigures(ctx.path))
+	ctx.path = nil
+}
+
+// strokePath strokes selected path and discards it
+func (ctx *context) strokePath() {
+	if ctx.path == nil {
+		return
+	}
+	ctx.Stroke(ctx.path)
+	ctx.path = nil
+}
+
+// strokeAndFillPath fills selected path, strokes its outline
+// and discards it
+func (ctx *context) strokeAndFillPath() {
+	if ctx.path == nil {
+		return
+	}
+	ctx.FillStroke(closeFigures(ctx.path))
+	ctx.path = nil
+}
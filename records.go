@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"image"
 	"io"
-	"math"
 	"os"
 
 	"github.com/llgcode/draw2d"
@@ -204,7 +203,9 @@ func (r *SetviewportorgexRecord) Draw(ctx *context) {
 
 type EOFRecord struct {
 	Record
-	nPalEntries, offPalEntries, SizeLast uint32
+	nPalEntries, offPalEntries uint32
+	PalEntries                 []LogPaletteEntry
+	SizeLast                   uint32
 }
 
 func readEOFRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
@@ -220,7 +221,12 @@ func readEOFRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 	}
 
 	if r.nPalEntries > 0 {
-		fmt.Fprintln(os.Stderr, "emf: nPalEntries found - ", r.nPalEntries)
+		// skipping UndefinedSpace
+		reader.Seek(int64(r.offPalEntries-16), io.SeekCurrent)
+		r.PalEntries = make([]LogPaletteEntry, r.nPalEntries)
+		if err := binary.Read(reader, binary.LittleEndian, &r.PalEntries); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := binary.Read(reader, binary.LittleEndian, &r.SizeLast); err != nil {
@@ -278,6 +284,10 @@ func readSetbkmodeRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 	return r, nil
 }
 
+func (r *SetbkmodeRecord) Draw(ctx *context) {
+	ctx.bkMode = r.BackgroundMode
+}
+
 type SetpolyfillmodeRecord struct {
 	Record
 	PolygonFillMode uint32
@@ -318,6 +328,10 @@ func readSettextalignRecord(reader *bytes.Reader, size uint32) (Recorder, error)
 	return r, nil
 }
 
+func (r *SettextalignRecord) Draw(ctx *context) {
+	ctx.textAlign = r.TextAlignmentMode
+}
+
 type SetstretchbltmodeRecord struct {
 	Record
 	StretchMode uint32
@@ -334,6 +348,74 @@ func readSetstretchbltmodeRecord(reader *bytes.Reader, size uint32) (Recorder, e
 	return r, nil
 }
 
+func (r *SetstretchbltmodeRecord) Draw(ctx *context) {
+	ctx.stretchMode = r.StretchMode
+}
+
+type SetmapperflagsRecord struct {
+	Record
+	Flags uint32
+}
+
+func readSetmapperflagsRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetmapperflagsRecord{}
+	r.Record = Record{Type: EMR_SETMAPPERFLAGS, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Flags); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetmapperflagsRecord) Draw(ctx *context) {
+	ctx.mapperFlags = r.Flags
+}
+
+type SettextjustificationRecord struct {
+	Record
+	nBreakExtra, nBreakCount int32
+}
+
+func readSettextjustificationRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SettextjustificationRecord{}
+	r.Record = Record{Type: EMR_SETTEXTJUSTIFICATION, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.nBreakExtra); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.nBreakCount); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SettextjustificationRecord) Draw(ctx *context) {
+	ctx.breakExtra = r.nBreakExtra
+	ctx.breakCount = r.nBreakCount
+}
+
+type SetcoloradjustmentRecord struct {
+	Record
+	ColorAdjustment ColorAdjustment
+}
+
+func readSetcoloradjustmentRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetcoloradjustmentRecord{}
+	r.Record = Record{Type: EMR_SETCOLORADJUSTMENT, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ColorAdjustment); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetcoloradjustmentRecord) Draw(ctx *context) {
+	ctx.colorAdjustment = &r.ColorAdjustment
+}
+
 type SettextcolorRecord struct {
 	Record
 	Color ColorRef
@@ -351,7 +433,7 @@ func readSettextcolorRecord(reader *bytes.Reader, size uint32) (Recorder, error)
 }
 
 func (r *SettextcolorRecord) Draw(ctx *context) {
-	ctx.SetFillColor(r.Color.GetColor())
+	ctx.textColor = ctx.getColor(r.Color)
 }
 
 type SetbkcolorRecord struct {
@@ -371,7 +453,7 @@ func readSetbkcolorRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *SetbkcolorRecord) Draw(ctx *context) {
-	ctx.SetFillColor(r.Color.GetColor())
+	ctx.bkColor = ctx.getColor(r.Color)
 }
 
 type MovetoexRecord struct {
@@ -391,7 +473,7 @@ func readMovetoexRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *MovetoexRecord) Draw(ctx *context) {
-	ctx.MoveTo(float64(r.Offset.X), float64(r.Offset.Y))
+	ctx.moveTo(float64(r.Offset.X), float64(r.Offset.Y))
 }
 
 type IntersectcliprectRecord struct {
@@ -410,6 +492,10 @@ func readIntersectcliprectRecord(reader *bytes.Reader, size uint32) (Recorder, e
 	return r, nil
 }
 
+func (r *IntersectcliprectRecord) Draw(ctx *context) {
+	ctx.setClip(intersectMask(ctx.clip, ctx.rectMask(r.Clip)))
+}
+
 type SavedcRecord struct {
 	Record
 }
@@ -419,7 +505,7 @@ func readSavedcRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *SavedcRecord) Draw(ctx *context) {
-	ctx.Save()
+	ctx.save()
 }
 
 type RestoredcRecord struct {
@@ -439,7 +525,7 @@ func readRestoredcRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *RestoredcRecord) Draw(ctx *context) {
-	ctx.Restore()
+	ctx.restore()
 }
 
 type SetworldtransformRecord struct {
@@ -466,6 +552,12 @@ func (r *SetworldtransformRecord) Draw(ctx *context) {
 	tr[3] = float64(r.XForm.M22)
 	tr[4] = float64(r.XForm.Dx)
 	tr[5] = float64(r.XForm.Dy)
+	if ctx.layout&LAYOUT_RTL != 0 {
+		// x axis of the device stays mirrored
+		m := ctx.mirrorTransform()
+		m.Compose(tr)
+		tr = m
+	}
 	ctx.SetMatrixTransform(tr)
 }
 
@@ -522,17 +614,39 @@ var StockObjects = map[uint32]interface{}{
 	NULL_PEN:            true,
 	SYSTEM_FONT:         LogFont{Height: 11},
 	DEVICE_DEFAULT_FONT: LogFont{Height: 11},
+	DEFAULT_PALETTE: &LogPalette{
+		Version: 0x0300,
+		PaletteEntries: []LogPaletteEntry{
+			{Red: 0x00, Green: 0x00, Blue: 0x00},
+			{Red: 0x80, Green: 0x00, Blue: 0x00},
+			{Red: 0x00, Green: 0x80, Blue: 0x00},
+			{Red: 0x80, Green: 0x80, Blue: 0x00},
+			{Red: 0x00, Green: 0x00, Blue: 0x80},
+			{Red: 0x80, Green: 0x00, Blue: 0x80},
+			{Red: 0x00, Green: 0x80, Blue: 0x80},
+			{Red: 0xC0, Green: 0xC0, Blue: 0xC0},
+			{Red: 0xC0, Green: 0xDC, Blue: 0xC0},
+			{Red: 0xA6, Green: 0xCA, Blue: 0xF0},
+			{Red: 0xFF, Green: 0xFB, Blue: 0xF0},
+			{Red: 0xA0, Green: 0xA0, Blue: 0xA4},
+			{Red: 0x80, Green: 0x80, Blue: 0x80},
+			{Red: 0xFF, Green: 0x00, Blue: 0x00},
+			{Red: 0x00, Green: 0xFF, Blue: 0x00},
+			{Red: 0xFF, Green: 0xFF, Blue: 0x00},
+			{Red: 0x00, Green: 0x00, Blue: 0xFF},
+			{Red: 0xFF, Green: 0x00, Blue: 0xFF},
+			{Red: 0x00, Green: 0xFF, Blue: 0xFF},
+			{Red: 0xFF, Green: 0xFF, Blue: 0xFF},
+		},
+	},
 }
 
 func (r *SelectobjectRecord) Draw(ctx *context) {
 
-	object, ok := StockObjects[r.ihObject]
+	object, ok := ctx.getObject(r.ihObject)
 	if !ok {
-		object, ok = ctx.objects[r.ihObject]
-		if !ok {
-			fmt.Fprintf(os.Stderr, "emf: object 0x%x not found\n", r.ihObject)
-			return
-		}
+		fmt.Fprintf(os.Stderr, "emf: object 0x%x not found\n", r.ihObject)
+		return
 	}
 
 	switch o := object.(type) {
@@ -544,12 +658,40 @@ func (r *SelectobjectRecord) Draw(ctx *context) {
 		}
 	case LogPen:
 		ctx.SetLineWidth(float64(o.Width.X))
-		ctx.SetStrokeColor(o.ColorRef.GetColor())
+		ctx.SetStrokeColor(ctx.getColor(o.ColorRef))
+		ctx.setPenStyle(o.PenStyle)
 	case LogPenEx:
 		ctx.SetLineWidth(float64(o.Width))
-		ctx.SetStrokeColor(o.ColorRef.GetColor())
+		ctx.SetStrokeColor(ctx.getColor(o.ColorRef))
+		ctx.setPenStyle(o.PenStyle)
 	case LogBrushEx:
-		ctx.SetFillColor(o.Color.GetColor())
+		ctx.SetFillColor(ctx.getColor(o.Color))
+	case LogFont:
+		ctx.font = o
+	}
+}
+
+// setPenStyle sets line caps and joins of the pen, they are
+// round unless other ones are requested for geometric pen
+func (ctx *context) setPenStyle(style uint32) {
+	ctx.SetLineCap(draw2d.RoundCap)
+	ctx.SetLineJoin(draw2d.RoundJoin)
+	if style&PS_GEOMETRIC == 0 {
+		return
+	}
+
+	switch style & 0x00000F00 {
+	case PS_ENDCAP_SQUARE:
+		ctx.SetLineCap(draw2d.SquareCap)
+	case PS_ENDCAP_FLAT:
+		ctx.SetLineCap(draw2d.ButtCap)
+	}
+
+	switch style & 0x0000F000 {
+	case PS_JOIN_BEVEL:
+		ctx.SetLineJoin(draw2d.BevelJoin)
+	case PS_JOIN_MITER:
+		ctx.SetLineJoin(draw2d.MiterJoin)
 	}
 }
 
@@ -644,8 +786,8 @@ func (r *RectangleRecord) Draw(ctx *context) {
 	ctx.LineTo(x2, y1)
 	ctx.LineTo(x2, y2)
 	ctx.LineTo(x1, y2)
-	ctx.LineTo(x1, y1)
-	ctx.FillStroke()
+	ctx.Close()
+	ctx.fillShape()
 }
 
 type ArcRecord struct {
@@ -673,15 +815,39 @@ func readArcRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *ArcRecord) Draw(ctx *context) {
-	center := r.Box.Center()
-	rx := (float64(r.Box.Right) - float64(r.Box.Left) - 1) / 2
-	ry := (float64(r.Box.Bottom) - float64(r.Box.Top) - 1) / 2
-	// angles are specified in radians
-	sa := math.Atan2(float64(r.Start.Y-center.Y), float64(r.Start.X-center.X))
-	ea := math.Atan2(float64(r.End.Y-center.Y), float64(r.End.X-center.X)) - sa
+	ctx.arc(r.Box, r.Start, r.End)
+	ctx.strokeShape()
+}
 
-	ctx.ArcTo(float64(center.X), float64(center.Y), rx, ry, sa, ea)
-	ctx.Stroke()
+type ArctoRecord struct {
+	Record
+	Box   RectL
+	Start PointL
+	End   PointL
+}
+
+func readArctoRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &ArctoRecord{}
+	r.Record = Record{Type: EMR_ARCTO, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Box); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.Start); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.End); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *ArctoRecord) Draw(ctx *context) {
+	// line from current position to the start of the arc
+	ctx.startFigure()
+	ctx.arc(r.Box, r.Start, r.End)
+	ctx.endFigure()
 }
 
 type LinetoRecord struct {
@@ -700,7 +866,80 @@ func readLinetoRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *LinetoRecord) Draw(ctx *context) {
+	ctx.startFigure()
 	ctx.LineTo(float64(r.Point.X), float64(r.Point.Y))
+	ctx.endFigure()
+}
+
+type SetpixelvRecord struct {
+	Record
+	Pixel PointL
+	Color ColorRef
+}
+
+func readSetpixelvRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetpixelvRecord{}
+	r.Record = Record{Type: EMR_SETPIXELV, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Pixel); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetpixelvRecord) Draw(ctx *context) {
+	if ctx.discard {
+		return
+	}
+	x, y := ctx.imagePoint(r.Pixel)
+	if mask := ctx.clipMask(); mask != nil && mask.AlphaAt(x, y).A < 0x80 {
+		return
+	}
+	ctx.img.Set(x, y, ctx.getColor(r.Color))
+}
+
+type ExtfloodfillRecord struct {
+	Record
+	Start         PointL
+	Color         ColorRef
+	FloodFillMode uint32
+}
+
+func readExtfloodfillRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &ExtfloodfillRecord{}
+	r.Record = Record{Type: EMR_EXTFLOODFILL, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Start); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Color); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.FloodFillMode); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Draw fills area with current brush. Area is bounded by the color
+// in FLOODFILLBORDER mode and consists of the color in FLOODFILLSURFACE mode.
+func (r *ExtfloodfillRecord) Draw(ctx *context) {
+	if ctx.discard {
+		return
+	}
+	x, y := ctx.imagePoint(r.Start)
+	mask := floodMask(ctx.img, x, y, ctx.getColor(r.Color), r.FloodFillMode == FLOODFILLSURFACE)
+	if mask != nil {
+		ctx.fillMask(mask, ctx.Current.FillColor)
+	}
 }
 
 type BeginpathRecord struct {
@@ -712,7 +951,7 @@ func readBeginpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *BeginpathRecord) Draw(ctx *context) {
-	ctx.BeginPath()
+	ctx.beginPath()
 }
 
 type EndpathRecord struct {
@@ -724,7 +963,43 @@ func readEndpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *EndpathRecord) Draw(ctx *context) {
-	ctx.Close()
+	ctx.endPath()
+}
+
+type FlattenpathRecord struct {
+	Record
+}
+
+func readFlattenpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return &FlattenpathRecord{Record{Type: EMR_FLATTENPATH, Size: size}}, nil
+}
+
+func (r *FlattenpathRecord) Draw(ctx *context) {
+	ctx.flattenPath()
+}
+
+type WidenpathRecord struct {
+	Record
+}
+
+func readWidenpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return &WidenpathRecord{Record{Type: EMR_WIDENPATH, Size: size}}, nil
+}
+
+func (r *WidenpathRecord) Draw(ctx *context) {
+	ctx.widenPath()
+}
+
+type AbortpathRecord struct {
+	Record
+}
+
+func readAbortpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return &AbortpathRecord{Record{Type: EMR_ABORTPATH, Size: size}}, nil
+}
+
+func (r *AbortpathRecord) Draw(ctx *context) {
+	ctx.abortPath()
 }
 
 type ClosefigureRecord struct {
@@ -755,7 +1030,7 @@ func readFillpathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *FillpathRecord) Draw(ctx *context) {
-	ctx.Fill()
+	ctx.fillPath()
 }
 
 type StrokeandfillpathRecord struct {
@@ -774,8 +1049,7 @@ func readStrokeandfillpathRecord(reader *bytes.Reader, size uint32) (Recorder, e
 }
 
 func (r *StrokeandfillpathRecord) Draw(ctx *context) {
-	ctx.Fill()
-	ctx.Stroke()
+	ctx.strokeAndFillPath()
 }
 
 type StrokepathRecord struct {
@@ -794,7 +1068,7 @@ func readStrokepathRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 }
 
 func (r *StrokepathRecord) Draw(ctx *context) {
-	ctx.Stroke()
+	ctx.strokePath()
 }
 
 type SelectclippathRecord struct {
@@ -812,16 +1086,12 @@ func readSelectclippathRecord(reader *bytes.Reader, size uint32) (Recorder, erro
 	return r, nil
 }
 
-type CommentRecord struct {
-	Record
-}
-
-func readCommentRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
-	r := &CommentRecord{}
-	r.Record = Record{Type: EMR_COMMENT, Size: size}
-	// skip record data
-	reader.Seek(int64(r.Size-8), io.SeekCurrent)
-	return r, nil
+func (r *SelectclippathRecord) Draw(ctx *context) {
+	if ctx.path == nil {
+		return
+	}
+	ctx.combineClip(ctx.pathMask(ctx.Current.FillRule, ctx.path), r.RegionMode)
+	ctx.path = nil
 }
 
 type ExtcreatefontindirectwRecord struct {
@@ -840,56 +1110,283 @@ func readExtcreatefontindirectwRecord(reader *bytes.Reader, size uint32) (Record
 
 	var err error
 
-	r.elw, err = readLogFont(reader)
+	offset := reader.Len()
+	r.elw, err = readLogFontW(reader, int(r.Size)-12)
 	if err != nil {
 		return nil, err
 	}
 
-	// skip the rest because we read only limited amount of data (LogFont) here
-	reader.Seek(int64(r.Size-(12+92)), io.SeekCurrent)
-
-	return r, nil
+	// skip the rest of the object
+	_, err = reader.Seek(int64(int(r.Size)-12-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
 }
 
 func (r *ExtcreatefontindirectwRecord) Draw(ctx *context) {
 	ctx.objects[r.ihFonts] = r.elw
 }
 
-type ExttextoutwRecord struct {
+// textoutRecord is a common part of text output records
+type textoutRecord struct {
 	Record
 	Bounds           RectL
 	iGraphicsMode    uint32
 	exScale, eyScale float32
-	wEmrText         EmrText
 }
 
-func readExttextoutwRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
-	r := &ExttextoutwRecord{}
-	r.Record = Record{Type: EMR_EXTTEXTOUTW, Size: size}
+func readTextoutRecord(reader *bytes.Reader, typ, size uint32) (textoutRecord, error) {
+	r := textoutRecord{}
+	r.Record = Record{Type: typ, Size: size}
 
 	if err := binary.Read(reader, binary.LittleEndian, &r.Bounds); err != nil {
-		return nil, err
+		return r, err
 	}
 
 	if err := binary.Read(reader, binary.LittleEndian, &r.iGraphicsMode); err != nil {
-		return nil, err
+		return r, err
 	}
 
 	if err := binary.Read(reader, binary.LittleEndian, &r.exScale); err != nil {
-		return nil, err
+		return r, err
 	}
 
 	if err := binary.Read(reader, binary.LittleEndian, &r.eyScale); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+type ExttextoutaRecord struct {
+	textoutRecord
+	aEmrText EmrText
+}
+
+func readExttextoutaRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &ExttextoutaRecord{}
+	r.textoutRecord, err = readTextoutRecord(reader, EMR_EXTTEXTOUTA, size)
+	if err != nil {
 		return nil, err
 	}
 
+	r.aEmrText, err = readEmrText(reader, offset, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the string and the spacing array
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *ExttextoutaRecord) Draw(ctx *context) {
+	ctx.drawText(r.aEmrText)
+}
+
+type ExttextoutwRecord struct {
+	textoutRecord
+	wEmrText EmrText
+}
+
+func readExttextoutwRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
 	var err error
-	r.wEmrText, err = readEmrText(reader, reader.Len()+36)
+	r := &ExttextoutwRecord{}
+	r.textoutRecord, err = readTextoutRecord(reader, EMR_EXTTEXTOUTW, size)
 	if err != nil {
 		return nil, err
 	}
 
-	return r, nil
+	r.wEmrText, err = readEmrText(reader, offset, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the string and the spacing array
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *ExttextoutwRecord) Draw(ctx *context) {
+	ctx.drawText(r.wEmrText)
+}
+
+type PolytextoutaRecord struct {
+	textoutRecord
+	cStrings  uint32
+	aEmrTexts []EmrText
+}
+
+func readPolytextoutaRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &PolytextoutaRecord{}
+	r.textoutRecord, err = readTextoutRecord(reader, EMR_POLYTEXTOUTA, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.cStrings); err != nil {
+		return nil, err
+	}
+
+	// each text object takes at least 24 bytes
+	if r.cStrings > uint32(reader.Len()/24) {
+		return nil, fmt.Errorf("invalid number of strings %d", r.cStrings)
+	}
+
+	r.aEmrTexts = make([]EmrText, r.cStrings)
+	for i := range r.aEmrTexts {
+		r.aEmrTexts[i], err = readEmrText(reader, offset, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *PolytextoutaRecord) Draw(ctx *context) {
+	for _, t := range r.aEmrTexts {
+		ctx.drawText(t)
+	}
+}
+
+type PolytextoutwRecord struct {
+	textoutRecord
+	cStrings  uint32
+	wEmrTexts []EmrText
+}
+
+func readPolytextoutwRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &PolytextoutwRecord{}
+	r.textoutRecord, err = readTextoutRecord(reader, EMR_POLYTEXTOUTW, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.cStrings); err != nil {
+		return nil, err
+	}
+
+	// each text object takes at least 24 bytes
+	if r.cStrings > uint32(reader.Len()/24) {
+		return nil, fmt.Errorf("invalid number of strings %d", r.cStrings)
+	}
+
+	r.wEmrTexts = make([]EmrText, r.cStrings)
+	for i := range r.wEmrTexts {
+		r.wEmrTexts[i], err = readEmrText(reader, offset, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *PolytextoutwRecord) Draw(ctx *context) {
+	for _, t := range r.wEmrTexts {
+		ctx.drawText(t)
+	}
+}
+
+type SmalltextoutRecord struct {
+	Record
+	x, y             int32
+	cChars           uint32
+	fuOptions        uint32
+	iGraphicsMode    uint32
+	exScale, eyScale float32
+	// text with clipping and opaquing rectangle,
+	// it has no intercharacter spacing
+	Text EmrText
+}
+
+func readSmalltextoutRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	r := &SmalltextoutRecord{}
+	r.Record = Record{Type: EMR_SMALLTEXTOUT, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.x); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.y); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.cChars); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.fuOptions); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.iGraphicsMode); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.exScale); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &r.eyScale); err != nil {
+		return nil, err
+	}
+
+	// characters take one or two bytes
+	chars := reader.Len() / 2
+	if r.fuOptions&ETO_SMALL_CHARS != 0 {
+		chars = reader.Len()
+	}
+	if r.cChars > uint32(chars) {
+		return nil, fmt.Errorf("invalid number of characters %d", r.cChars)
+	}
+
+	r.Text = EmrText{
+		Reference: PointL{X: r.x, Y: r.y},
+		Chars:     r.cChars,
+		Options:   r.fuOptions,
+	}
+
+	if r.fuOptions&ETO_NO_RECT == 0 {
+		if err := binary.Read(reader, binary.LittleEndian, &r.Text.Rectangle); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.fuOptions&ETO_SMALL_CHARS != 0 {
+		// low bytes of UTF-16 characters or glyph indices
+		b := make([]byte, r.cChars)
+		if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
+			return nil, err
+		}
+		w := make([]uint16, len(b))
+		for i, c := range b {
+			w[i] = uint16(c)
+		}
+		r.Text.setWide(w)
+	} else {
+		b := make([]uint16, r.cChars)
+		if err := binary.Read(reader, binary.LittleEndian, &b); err != nil {
+			return nil, err
+		}
+		r.Text.setWide(b)
+	}
+
+	// string is padded to 32-bit boundary
+	_, err := reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *SmalltextoutRecord) Draw(ctx *context) {
+	ctx.drawText(r.Text)
 }
 
 type Polybezier16Record struct {
@@ -928,6 +1425,7 @@ func (r *Polybezier16Record) Draw(ctx *context) {
 			float64(r.aPoints[i+2].X), float64(r.aPoints[i+2].Y),
 		)
 	}
+	ctx.strokeShape()
 }
 
 type Polygon16Record struct {
@@ -963,7 +1461,7 @@ func (r *Polygon16Record) Draw(ctx *context) {
 		ctx.LineTo(float64(r.aPoints[i].X), float64(r.aPoints[i].Y))
 	}
 	ctx.Close()
-	ctx.FillStroke()
+	ctx.fillShape()
 }
 
 type Polyline16Record struct {
@@ -998,7 +1496,69 @@ func (r *Polyline16Record) Draw(ctx *context) {
 	for i := 1; i < int(r.Count); i++ {
 		ctx.LineTo(float64(r.aPoints[i].X), float64(r.aPoints[i].Y))
 	}
-	// ctx.Stroke()
+	ctx.strokeShape()
+}
+
+type PolybeziertoRecord struct {
+	Record
+	Bounds  RectL
+	Count   uint32
+	aPoints []PointL
+}
+
+func readPolybeziertoRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &PolybeziertoRecord{}
+	r.Record = Record{Type: EMR_POLYBEZIERTO, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Bounds); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Count); err != nil {
+		return nil, err
+	}
+
+	r.aPoints = make([]PointL, r.Count)
+	if err := binary.Read(reader, binary.LittleEndian, &r.aPoints); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PolybeziertoRecord) Draw(ctx *context) {
+	ctx.polybezierTo(r.aPoints)
+}
+
+type PolylinetoRecord struct {
+	Record
+	Bounds  RectL
+	Count   uint32
+	aPoints []PointL
+}
+
+func readPolylinetoRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &PolylinetoRecord{}
+	r.Record = Record{Type: EMR_POLYLINETO, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Bounds); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Count); err != nil {
+		return nil, err
+	}
+
+	r.aPoints = make([]PointL, r.Count)
+	if err := binary.Read(reader, binary.LittleEndian, &r.aPoints); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PolylinetoRecord) Draw(ctx *context) {
+	ctx.polylineTo(r.aPoints)
 }
 
 type Polybezierto16Record struct {
@@ -1029,13 +1589,7 @@ func readPolybezierto16Record(reader *bytes.Reader, size uint32) (Recorder, erro
 }
 
 func (r *Polybezierto16Record) Draw(ctx *context) {
-	for i := 0; i < int(r.Count); i = i + 3 {
-		ctx.CubicCurveTo(
-			float64(r.aPoints[i].X), float64(r.aPoints[i].Y),
-			float64(r.aPoints[i+1].X), float64(r.aPoints[i+1].Y),
-			float64(r.aPoints[i+2].X), float64(r.aPoints[i+2].Y),
-		)
-	}
+	ctx.polybezierTo(pointsL(r.aPoints))
 }
 
 type Polylineto16Record struct {
@@ -1066,9 +1620,7 @@ func readPolylineto16Record(reader *bytes.Reader, size uint32) (Recorder, error)
 }
 
 func (r *Polylineto16Record) Draw(ctx *context) {
-	for i := 0; i < int(r.Count); i++ {
-		ctx.LineTo(float64(r.aPoints[i].X), float64(r.aPoints[i].Y))
-	}
+	ctx.polylineTo(pointsL(r.aPoints))
 }
 
 type Polypolygon16Record struct {
@@ -1120,7 +1672,7 @@ func (r *Polypolygon16Record) Draw(ctx *context) {
 		idx += pCount
 		ctx.Close()
 	}
-	ctx.FillStroke()
+	ctx.fillShape()
 }
 
 type ExtcreatepenRecord struct {
@@ -1194,6 +1746,193 @@ func (r *ExtcreatepenRecord) Draw(ctx *context) {
 	ctx.objects[r.ihPen] = r.elp
 }
 
+type CreatepaletteRecord struct {
+	Record
+	ihPal      uint32
+	LogPalette LogPalette
+}
+
+func readCreatepaletteRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &CreatepaletteRecord{}
+	r.Record = Record{Type: EMR_CREATEPALETTE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihPal); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.LogPalette, err = readLogPalette(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *CreatepaletteRecord) Draw(ctx *context) {
+	// palette entries could be changed by other records
+	// so object table gets its own copy
+	ctx.objects[r.ihPal] = &LogPalette{
+		Version:        r.LogPalette.Version,
+		PaletteEntries: append([]LogPaletteEntry(nil), r.LogPalette.PaletteEntries...),
+	}
+}
+
+type SelectpaletteRecord struct {
+	Record
+	ihPal uint32
+}
+
+func readSelectpaletteRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SelectpaletteRecord{}
+	r.Record = Record{Type: EMR_SELECTPALETTE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihPal); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SelectpaletteRecord) Draw(ctx *context) {
+	object, ok := ctx.getObject(r.ihPal)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: palette 0x%x not found\n", r.ihPal)
+		return
+	}
+
+	if pal, ok := object.(*LogPalette); ok {
+		ctx.palette = pal
+	}
+}
+
+type SetpaletteentriesRecord struct {
+	Record
+	ihPal           uint32
+	Start           uint32
+	NumberOfEntries uint32
+	PaletteEntries  []LogPaletteEntry
+}
+
+func readSetpaletteentriesRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetpaletteentriesRecord{}
+	r.Record = Record{Type: EMR_SETPALETTEENTRIES, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihPal); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Start); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.NumberOfEntries); err != nil {
+		return nil, err
+	}
+
+	if r.NumberOfEntries > uint32(reader.Len()/4) {
+		return nil, fmt.Errorf("invalid number of palette entries %d", r.NumberOfEntries)
+	}
+
+	r.PaletteEntries = make([]LogPaletteEntry, r.NumberOfEntries)
+	if err := binary.Read(reader, binary.LittleEndian, &r.PaletteEntries); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetpaletteentriesRecord) Draw(ctx *context) {
+	pal, ok := ctx.objects[r.ihPal].(*LogPalette)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: palette 0x%x not found\n", r.ihPal)
+		return
+	}
+
+	for i, e := range r.PaletteEntries {
+		if idx := int(r.Start) + i; idx < len(pal.PaletteEntries) {
+			pal.PaletteEntries[idx] = e
+		}
+	}
+}
+
+type ResizepaletteRecord struct {
+	Record
+	ihPal           uint32
+	NumberOfEntries uint32
+}
+
+func readResizepaletteRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &ResizepaletteRecord{}
+	r.Record = Record{Type: EMR_RESIZEPALETTE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihPal); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.NumberOfEntries); err != nil {
+		return nil, err
+	}
+
+	// palette can't have more than 1024 entries
+	if r.NumberOfEntries == 0 || r.NumberOfEntries > 0x400 {
+		return nil, fmt.Errorf("invalid number of palette entries %d", r.NumberOfEntries)
+	}
+
+	return r, nil
+}
+
+func (r *ResizepaletteRecord) Draw(ctx *context) {
+	pal, ok := ctx.objects[r.ihPal].(*LogPalette)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emf: palette 0x%x not found\n", r.ihPal)
+		return
+	}
+
+	// new entries are black
+	entries := make([]LogPaletteEntry, r.NumberOfEntries)
+	copy(entries, pal.PaletteEntries)
+	pal.PaletteEntries = entries
+}
+
+type RealizepaletteRecord struct {
+	Record
+}
+
+func readRealizepaletteRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return &RealizepaletteRecord{Record{Type: EMR_REALIZEPALETTE, Size: size}}, nil
+}
+
+// Draw does nothing because there is no system palette to map
+// logical palette into, colors are taken from selected palette directly.
+func (r *RealizepaletteRecord) Draw(ctx *context) {}
+
+type SetlayoutRecord struct {
+	Record
+	LayoutMode uint32
+}
+
+func readSetlayoutRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetlayoutRecord{}
+	r.Record = Record{Type: EMR_SETLAYOUT, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.LayoutMode); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetlayoutRecord) Draw(ctx *context) {
+	if (r.LayoutMode^ctx.layout)&LAYOUT_RTL != 0 {
+		// x axis of the device is mirrored around the center of image
+		tr := ctx.mirrorTransform()
+		tr.Compose(ctx.GetMatrixTransform())
+		ctx.SetMatrixTransform(tr)
+	}
+	ctx.layout = r.LayoutMode
+}
+
 type SeticmmodeRecord struct {
 	Record
 	ICMMode uint32
@@ -1210,14 +1949,21 @@ func readSeticmmodeRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
 	return r, nil
 }
 
+func (r *SeticmmodeRecord) Draw(ctx *context) {
+	switch r.ICMMode {
+	case ICM_OFF, ICM_ON, ICM_DONE_OUTSIDEDC:
+		ctx.icmMode = r.ICMMode
+	}
+}
+
 // map of readers for records
 var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_HEADER:                  readHeaderRecord,
 	EMR_POLYBEZIER:              nil,
 	EMR_POLYGON:                 nil,
 	EMR_POLYLINE:                nil,
-	EMR_POLYBEZIERTO:            nil,
-	EMR_POLYLINETO:              nil,
+	EMR_POLYBEZIERTO:            readPolybeziertoRecord,
+	EMR_POLYLINETO:              readPolylinetoRecord,
 	EMR_POLYPOLYLINE:            nil,
 	EMR_POLYPOLYGON:             nil,
 	EMR_SETWINDOWEXTEX:          readSetwindowextexRecord,
@@ -1226,21 +1972,21 @@ var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_SETVIEWPORTORGEX:        readSetviewportorgexRecord,
 	EMR_SETBRUSHORGEX:           nil,
 	EMR_EOF:                     readEOFRecord,
-	EMR_SETPIXELV:               nil,
-	EMR_SETMAPPERFLAGS:          nil,
+	EMR_SETPIXELV:               readSetpixelvRecord,
+	EMR_SETMAPPERFLAGS:          readSetmapperflagsRecord,
 	EMR_SETMAPMODE:              readSetmapmodeRecord,
 	EMR_SETBKMODE:               readSetbkmodeRecord,
 	EMR_SETPOLYFILLMODE:         readSetpolyfillmodeRecord,
 	EMR_SETROP2:                 nil,
 	EMR_SETSTRETCHBLTMODE:       readSetstretchbltmodeRecord,
 	EMR_SETTEXTALIGN:            readSettextalignRecord,
-	EMR_SETCOLORADJUSTMENT:      nil,
+	EMR_SETCOLORADJUSTMENT:      readSetcoloradjustmentRecord,
 	EMR_SETTEXTCOLOR:            readSettextcolorRecord,
 	EMR_SETBKCOLOR:              readSetbkcolorRecord,
-	EMR_OFFSETCLIPRGN:           nil,
+	EMR_OFFSETCLIPRGN:           readOffsetcliprgnRecord,
 	EMR_MOVETOEX:                readMovetoexRecord,
-	EMR_SETMETARGN:              nil,
-	EMR_EXCLUDECLIPRECT:         nil,
+	EMR_SETMETARGN:              readSetmetargnRecord,
+	EMR_EXCLUDECLIPRECT:         readExcludecliprectRecord,
 	EMR_INTERSECTCLIPRECT:       readIntersectcliprectRecord,
 	EMR_SCALEVIEWPORTEXTEX:      nil,
 	EMR_SCALEWINDOWEXTEX:        nil,
@@ -1259,14 +2005,14 @@ var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_ARC:                     readArcRecord,
 	EMR_CHORD:                   nil,
 	EMR_PIE:                     nil,
-	EMR_SELECTPALETTE:           nil,
-	EMR_CREATEPALETTE:           nil,
-	EMR_SETPALETTEENTRIES:       nil,
-	EMR_RESIZEPALETTE:           nil,
-	EMR_REALIZEPALETTE:          nil,
-	EMR_EXTFLOODFILL:            nil,
+	EMR_SELECTPALETTE:           readSelectpaletteRecord,
+	EMR_CREATEPALETTE:           readCreatepaletteRecord,
+	EMR_SETPALETTEENTRIES:       readSetpaletteentriesRecord,
+	EMR_RESIZEPALETTE:           readResizepaletteRecord,
+	EMR_REALIZEPALETTE:          readRealizepaletteRecord,
+	EMR_EXTFLOODFILL:            readExtfloodfillRecord,
 	EMR_LINETO:                  readLinetoRecord,
-	EMR_ARCTO:                   nil,
+	EMR_ARCTO:                   readArctoRecord,
 	EMR_POLYDRAW:                nil,
 	EMR_SETARCDIRECTION:         nil,
 	EMR_SETMITERLIMIT:           nil,
@@ -1276,16 +2022,16 @@ var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_FILLPATH:                readFillpathRecord,
 	EMR_STROKEANDFILLPATH:       readStrokeandfillpathRecord,
 	EMR_STROKEPATH:              readStrokepathRecord,
-	EMR_FLATTENPATH:             nil,
-	EMR_WIDENPATH:               nil,
+	EMR_FLATTENPATH:             readFlattenpathRecord,
+	EMR_WIDENPATH:               readWidenpathRecord,
 	EMR_SELECTCLIPPATH:          readSelectclippathRecord,
-	EMR_ABORTPATH:               nil,
+	EMR_ABORTPATH:               readAbortpathRecord,
 	EMR_COMMENT:                 readCommentRecord,
-	EMR_FILLRGN:                 nil,
-	EMR_FRAMERGN:                nil,
-	EMR_INVERTRGN:               nil,
-	EMR_PAINTRGN:                nil,
-	EMR_EXTSELECTCLIPRGN:        nil,
+	EMR_FILLRGN:                 readFillrgnRecord,
+	EMR_FRAMERGN:                readFramergnRecord,
+	EMR_INVERTRGN:               readInvertrgnRecord,
+	EMR_PAINTRGN:                readPaintrgnRecord,
+	EMR_EXTSELECTCLIPRGN:        readExtselectcliprgnRecord,
 	EMR_BITBLT:                  readBitbltRecord,
 	EMR_STRETCHBLT:              readStretchbltRecord,
 	EMR_MASKBLT:                 nil,
@@ -1293,7 +2039,7 @@ var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_SETDIBITSTODEVICE:       nil,
 	EMR_STRETCHDIBITS:           readStretchdibitsRecord,
 	EMR_EXTCREATEFONTINDIRECTW:  readExtcreatefontindirectwRecord,
-	EMR_EXTTEXTOUTA:             nil,
+	EMR_EXTTEXTOUTA:             readExttextoutaRecord,
 	EMR_EXTTEXTOUTW:             readExttextoutwRecord,
 	EMR_POLYBEZIER16:            readPolybezier16Record,
 	EMR_POLYGON16:               readPolygon16Record,
@@ -1306,29 +2052,29 @@ var records = map[uint32]func(*bytes.Reader, uint32) (Recorder, error){
 	EMR_CREATEMONOBRUSH:         nil,
 	EMR_CREATEDIBPATTERNBRUSHPT: nil,
 	EMR_EXTCREATEPEN:            readExtcreatepenRecord,
-	EMR_POLYTEXTOUTA:            nil,
-	EMR_POLYTEXTOUTW:            nil,
+	EMR_POLYTEXTOUTA:            readPolytextoutaRecord,
+	EMR_POLYTEXTOUTW:            readPolytextoutwRecord,
 	EMR_SETICMMODE:              readSeticmmodeRecord,
-	EMR_CREATECOLORSPACE:        nil,
-	EMR_SETCOLORSPACE:           nil,
-	EMR_DELETECOLORSPACE:        nil,
+	EMR_CREATECOLORSPACE:        readCreatecolorspaceRecord,
+	EMR_SETCOLORSPACE:           readSetcolorspaceRecord,
+	EMR_DELETECOLORSPACE:        readDeletecolorspaceRecord,
 	EMR_GLSRECORD:               nil,
 	EMR_GLSBOUNDEDRECORD:        nil,
 	EMR_PIXELFORMAT:             nil,
 	EMR_DRAWESCAPE:              nil,
 	EMR_EXTESCAPE:               nil,
-	EMR_SMALLTEXTOUT:            nil,
+	EMR_SMALLTEXTOUT:            readSmalltextoutRecord,
 	EMR_FORCEUFIMAPPING:         nil,
 	EMR_NAMEDESCAPE:             nil,
 	EMR_COLORCORRECTPALETTE:     nil,
-	EMR_SETICMPROFILEA:          nil,
-	EMR_SETICMPROFILEW:          nil,
+	EMR_SETICMPROFILEA:          readSeticmprofileaRecord,
+	EMR_SETICMPROFILEW:          readSeticmprofilewRecord,
 	EMR_ALPHABLEND:              nil,
-	EMR_SETLAYOUT:               nil,
+	EMR_SETLAYOUT:               readSetlayoutRecord,
 	EMR_TRANSPARENTBLT:          nil,
 	EMR_GRADIENTFILL:            nil,
 	EMR_SETLINKEDUFIS:           nil,
-	EMR_SETTEXTJUSTIFICATION:    nil,
-	EMR_COLORMATCHTOTARGETW:     nil,
-	EMR_CREATECOLORSPACEW:       nil,
+	EMR_SETTEXTJUSTIFICATION:    readSettextjustificationRecord,
+	EMR_COLORMATCHTOTARGETW:     readColormatchtotargetwRecord,
+	EMR_CREATECOLORSPACEW:       readCreatecolorspacewRecord,
 }
@@ -5,11 +5,17 @@ import (
 	"encoding/binary"
 	"fmt"
 	"image"
-	"image/draw"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"math"
+	"math/bits"
 	"os"
 
-	"github.com/disintegration/imaging"
+	"github.com/llgcode/draw2d"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
 )
 
 type bitmapRecord struct {
@@ -26,7 +32,7 @@ type bitmapRecord struct {
 	// only for EMR_STRETCHBLT
 	cxSrc, cySrc int32
 
-	BmiSrc  BitmapInfoHeader
+	BmiSrc  BitmapInfo
 	BitsSrc []byte
 }
 
@@ -118,12 +124,13 @@ func (r *bitmapRecord) read(reader *bytes.Reader) (Recorder, error) {
 	// BitmapBuffer
 	// skipping UndefinedSpace1
 	reader.Seek(int64(r.offBmiSrc-rsize), io.SeekCurrent)
-	if err := binary.Read(reader, binary.LittleEndian, &r.BmiSrc); err != nil {
+	var err error
+	if r.BmiSrc, err = readBitmapInfo(reader, r.UsageSrc); err != nil {
 		return nil, err
 	}
 
 	// skipping UndefinedSpace2
-	reader.Seek(int64(r.offBitsSrc-rsize-r.BmiSrc.HeaderSize), io.SeekCurrent)
+	reader.Seek(int64(r.offBitsSrc-rsize-r.BmiSrc.size()), io.SeekCurrent)
 	r.BitsSrc = make([]byte, r.cbBitsSrc)
 	if _, err := reader.Read(r.BitsSrc); err != nil {
 		return nil, err
@@ -132,11 +139,32 @@ func (r *bitmapRecord) read(reader *bytes.Reader) (Recorder, error) {
 	return r, nil
 }
 
-func (r *bitmapRecord) readImage() image.Image {
+// readImage decodes bitmap using colors as a color table for indexed bitmaps
+func (r *bitmapRecord) readImage(colors []color.RGBA) image.Image {
+
+	// bits contain complete compressed image
+	switch r.BmiSrc.Compression {
+	case BI_JPEG:
+		img, err := jpeg.Decode(bytes.NewReader(r.BitsSrc))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "emf: unable to decode jpeg bitmap:", err)
+			return nil
+		}
+		return img
+
+	case BI_PNG:
+		img, err := png.Decode(bytes.NewReader(r.BitsSrc))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "emf: unable to decode png bitmap:", err)
+			return nil
+		}
+		return img
+	}
 
 	// bytes per pixel
 	bpp, ok := map[uint16]int{
 		BI_BITCOUNT_1: 0,
+		BI_BITCOUNT_2: 0,
 		BI_BITCOUNT_3: 1,
 		BI_BITCOUNT_5: 3,
 		BI_BITCOUNT_4: 2,
@@ -150,78 +178,53 @@ func (r *bitmapRecord) readImage() image.Image {
 
 	// src image width and height
 	width, height := int(r.BmiSrc.Width), int(r.BmiSrc.Height)
+	if height < 0 {
+		height = -height
+	}
 	// bytes per line with padding to 4 bytes
 	bpl := ((width*int(r.BmiSrc.BitCount) + 31) & 0xFFFFFFE0) / 8
 
-	switch r.BmiSrc.BitCount {
-	case BI_BITCOUNT_1:
-		img := image.NewRGBA(image.Rect(0, 0, width, height))
-		// we don't read it
-		colors := map[int][]byte{0: {0, 0, 0, 0}, 1: {255, 255, 255, 0}}
-
-		mask := []byte{0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x01}
-
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				p := img.PixOffset(x, height-y-1)
-				c := colors[0]
-				if (r.BitsSrc[y*bpl+x/8] & mask[x%8]) > 0 {
-					c = colors[1]
-				}
-				img.Pix[p+0] = c[2]
-				img.Pix[p+1] = c[1]
-				img.Pix[p+2] = c[0]
-				img.Pix[p+3] = 0xff
-			}
-		}
-		return img
+	if len(r.BitsSrc) < bpl*height {
+		fmt.Fprintln(os.Stderr, "emf: not enough bitmap data")
+		return nil
+	}
 
-	case BI_BITCOUNT_3:
-		img := image.NewGray(image.Rect(0, 0, width, height))
-		ix := 0
-		// BMP images are stored bottom-up
-		for y := height - 1; y >= 0; y-- {
-			b := r.BitsSrc[y*bpl : y*bpl+bpl]
-			p := img.Pix[ix*img.Stride : ix*img.Stride+img.Stride]
-			for i, j := 0, 0; i < len(p); i, j = i+1, j+bpp {
-				p[i] = b[j]
-			}
-			ix = ix + 1
+	// row returns bitmap data for line y of the image
+	row := func(y int) []byte {
+		// BMP images are stored bottom-up unless height is negative
+		if !r.BmiSrc.TopDown() {
+			y = height - y - 1
 		}
-		return img
+		return r.BitsSrc[y*bpl : y*bpl+bpl]
+	}
 
-	case BI_BITCOUNT_4:
+	switch r.BmiSrc.BitCount {
+	case BI_BITCOUNT_1, BI_BITCOUNT_2, BI_BITCOUNT_3:
 		if r.BmiSrc.Compression != BI_RGB {
 			fmt.Fprintln(os.Stderr, "emf: unsupported compression type", r.BmiSrc.Compression)
 			return nil
 		}
 
-		img := image.NewRGBA(image.Rect(0, 0, width, height))
-		ix := 0
-		// BMP images are stored bottom-up
-		for y := height - 1; y >= 0; y-- {
-			b := r.BitsSrc[y*bpl : y*bpl+bpl]
-			p := img.Pix[ix*img.Stride : ix*img.Stride+img.Stride]
-			for i, j := 0, 0; i < len(p); i, j = i+4, j+bpp {
-				// The relative intensities of red, green, and blue
-				// are represented with 5 bits for each color component.
-				c := uint16(b[j+1])<<8 | uint16(b[j])
-				p[i+0] = uint8((c>>10)&0x001f) * 8
-				p[i+1] = uint8((c>>5)&0x001f) * 8
-				p[i+2] = uint8(c&0x001f) * 8
-				p[i+3] = 0xff
+		img := image.NewPaletted(image.Rect(0, 0, width, height), r.BmiSrc.palette(colors))
+		bc := int(r.BmiSrc.BitCount)
+		mask := byte(1<<bc - 1)
+
+		for y := 0; y < height; y++ {
+			b := row(y)
+			p := img.Pix[y*img.Stride : y*img.Stride+width]
+			for x := range p {
+				// pixels are packed starting from the most significant bits
+				i := x * bc
+				p[x] = (b[i/8] >> (8 - bc - i%8)) & mask
 			}
-			ix = ix + 1
 		}
 		return img
 
-	case BI_BITCOUNT_5, BI_BITCOUNT_6:
+	case BI_BITCOUNT_5:
 		img := image.NewRGBA(image.Rect(0, 0, width, height))
-		ix := 0
-		// BMP images are stored bottom-up
-		for y := height - 1; y >= 0; y-- {
-			b := r.BitsSrc[y*bpl : y*bpl+bpl]
-			p := img.Pix[ix*img.Stride : ix*img.Stride+img.Stride]
+		for y := 0; y < height; y++ {
+			b := row(y)
+			p := img.Pix[y*img.Stride : y*img.Stride+img.Stride]
 			for i, j := 0, 0; i < len(p); i, j = i+4, j+bpp {
 				// color in BMP stored in BGR order
 				p[i+0] = b[j+2]
@@ -229,33 +232,142 @@ func (r *bitmapRecord) readImage() image.Image {
 				p[i+2] = b[j+0]
 				p[i+3] = 0xff
 			}
-			ix = ix + 1
+		}
+		return img
+
+	case BI_BITCOUNT_4, BI_BITCOUNT_6:
+		if r.BmiSrc.Compression != BI_RGB && !r.BmiSrc.bitfields() {
+			fmt.Fprintln(os.Stderr, "emf: unsupported compression type", r.BmiSrc.Compression)
+			return nil
+		}
+
+		rm, gm, bm, am := r.BmiSrc.masks()
+
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			b := row(y)
+			p := img.Pix[y*img.Stride : y*img.Stride+img.Stride]
+			for i, j := 0, 0; i < len(p); i, j = i+4, j+bpp {
+				var c uint32
+				if bpp == 2 {
+					c = uint32(binary.LittleEndian.Uint16(b[j:]))
+				} else {
+					c = binary.LittleEndian.Uint32(b[j:])
+				}
+				p[i+0] = bitfield(c, rm)
+				p[i+1] = bitfield(c, gm)
+				p[i+2] = bitfield(c, bm)
+				p[i+3] = 0xff
+				if am != 0 {
+					p[i+3] = bitfield(c, am)
+				}
+			}
 		}
 		return img
 	}
 	return nil
 }
 
+// bitfield extracts color component selected by mask and scales it to 8 bits
+func bitfield(c, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	c = (c & mask) >> bits.TrailingZeros32(mask)
+	max := uint64(1)<<bits.OnesCount32(mask) - 1
+	return uint8(uint64(c) * 0xff / max)
+}
+
+// srcRect returns source rectangle in bitmap pixels
+func (r *bitmapRecord) srcRect(img image.Image) image.Rectangle {
+	x, y, cx, cy := float64(r.xSrc), float64(r.ySrc), float64(r.cxSrc), float64(r.cySrc)
+	if r.Type == EMR_BITBLT {
+		// source has the same size as destination
+		cx, cy = float64(r.cxDest), float64(r.cyDest)
+	}
+
+	// XformSrc is not present in EMR_STRETCHDIBITS and could be empty
+	xf := r.XformSrc
+	if xf != (XForm{}) {
+		tr := draw2d.Matrix{
+			float64(xf.M11), float64(xf.M12), float64(xf.M21),
+			float64(xf.M22), float64(xf.Dx), float64(xf.Dy)}
+		x0, y0, x1, y1 := tr.TransformRectangle(x, y, x+cx, y+cy)
+		x, y, cx, cy = x0, y0, x1-x0, y1-y0
+	}
+
+	// origin of the bottom-up DIB is the lower-left corner
+	if r.Type == EMR_STRETCHDIBITS && !r.BmiSrc.TopDown() {
+		y = float64(img.Bounds().Dy()) - y - cy
+	}
+
+	rect := image.Rect(
+		int(math.Round(x)), int(math.Round(y)),
+		int(math.Round(x+cx)), int(math.Round(y+cy)))
+	return rect.Intersect(img.Bounds())
+}
+
 func (r *bitmapRecord) Draw(ctx *context) {
-	img := r.readImage()
+	if ctx.discard {
+		return
+	}
+
+	colors := r.BmiSrc.Colors
+	if r.UsageSrc == DIB_PAL_COLORS {
+		colors = ctx.paletteColors(r.BmiSrc.Indexes)
+	}
+
+	if ctx.colorMatching() {
+		matched := make([]color.RGBA, len(colors))
+		for i, c := range colors {
+			matched[i] = ctx.matchColor(c)
+		}
+		colors = matched
+	}
+
+	img := r.readImage(colors)
 	if img == nil {
 		return
 	}
 
-	// dest image rectangle
-	tx, ty := ctx.GetMatrixTransform().GetTranslation()
-	rect := image.Rect(
-		int(r.Bounds.Left)+int(tx), int(r.Bounds.Top)+int(ty),
-		int(r.Bounds.Right)+int(tx), int(r.Bounds.Bottom)+int(ty))
+	sr := r.srcRect(img)
+	if sr.Empty() || r.cxDest == 0 || r.cyDest == 0 {
+		return
+	}
+
+	if _, indexed := img.(*image.Paletted); ctx.colorMatching() && !indexed {
+		// colors of indexed bitmaps are converted in color table
+		img = ctx.matchImage(img, sr)
+	}
 
-	// Record bounds often differs for 1px with image size.
-	// Call scaling only if image size is bigger than record bounds because
-	// this procedure is very expensive.
-	if img.Bounds().Dx() > rect.Dx()+1 && img.Bounds().Dy() > rect.Dy()+1 {
-		img = imaging.Resize(img, rect.Dx(), rect.Dy(), imaging.CatmullRom)
+	if ctx.stretchMode == STRETCH_HALFTONE && ctx.colorAdjustment != nil {
+		img = adjustColors(img, sr, *ctx.colorAdjustment)
 	}
 
-	draw.Draw(ctx.img, rect, img, image.Point{}, draw.Over)
+	// source pixels to logical units of destination rectangle
+	src := draw2d.NewTranslationMatrix(float64(r.xDest), float64(r.yDest))
+	src.Scale(float64(r.cxDest)/float64(sr.Dx()), float64(r.cyDest)/float64(sr.Dy()))
+	src.Translate(-float64(sr.Min.X), -float64(sr.Min.Y))
+	if ctx.layout&(LAYOUT_RTL|LAYOUT_BITMAPORIENTATIONPRESERVED) == LAYOUT_RTL|LAYOUT_BITMAPORIENTATIONPRESERVED {
+		// bitmap is flipped to cancel mirroring of the layout
+		src.Compose(draw2d.Matrix{-1, 0, 0, 1, float64(sr.Min.X + sr.Max.X), 0})
+	}
+	// and then to device
+	tr := ctx.GetMatrixTransform()
+	tr.Compose(src)
+
+	var interp draw.Interpolator = draw.NearestNeighbor
+	if ctx.stretchMode == STRETCH_HALFTONE {
+		interp = draw.CatmullRom
+	}
+
+	var opts *draw.Options
+	if mask := ctx.clipMask(); mask != nil {
+		opts = &draw.Options{DstMask: mask}
+	}
+
+	interp.Transform(ctx.img, f64.Aff3{tr[0], tr[2], tr[4], tr[1], tr[3], tr[5]},
+		img, sr, draw.Over, opts)
 }
 
 type BitbltRecord struct {
@@ -350,12 +462,13 @@ func readStretchdibitsRecord(reader *bytes.Reader, size uint32) (Recorder, error
 	// BitmapBuffer
 	// skipping UndefinedSpace1
 	reader.Seek(int64(r.offBmiSrc-80), io.SeekCurrent)
-	if err := binary.Read(reader, binary.LittleEndian, &r.BmiSrc); err != nil {
+	var err error
+	if r.BmiSrc, err = readBitmapInfo(reader, r.UsageSrc); err != nil {
 		return nil, err
 	}
 
 	// skipping UndefinedSpace2
-	reader.Seek(int64(r.offBitsSrc-80-r.BmiSrc.HeaderSize), io.SeekCurrent)
+	reader.Seek(int64(r.offBitsSrc-80-r.BmiSrc.size()), io.SeekCurrent)
 	r.BitsSrc = make([]byte, r.cbBitsSrc)
 	if _, err := reader.Read(r.BitsSrc); err != nil {
 		return nil, err
@@ -363,3 +476,88 @@ func readStretchdibitsRecord(reader *bytes.Reader, size uint32) (Recorder, error
 
 	return r, nil
 }
+
+// matchImage returns pixels of the rectangle of the image
+// converted from selected color space to sRGB
+func (ctx *context) matchImage(img image.Image, rect image.Rectangle) image.Image {
+	dst := image.NewRGBA(rect)
+	draw.Draw(dst, rect, img, rect.Min, draw.Src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		p := dst.Pix[i : i+4 : i+4]
+		c := ctx.matchColor(color.RGBA{p[0], p[1], p[2], p[3]})
+		p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+	}
+	return dst
+}
+
+// white points of illuminants in linear sRGB relative to D65 white point
+var illuminants = map[uint16][3]float64{
+	ILLUMINANT_TUNGSTEN:    {1.8454, 0.8262, 0.2333},
+	ILLUMINANT_B:           {1.2488, 0.9510, 0.7530},
+	ILLUMINANT_DAYLIGHT:    {1.0516, 0.9745, 1.1004},
+	ILLUMINANT_D50:         {1.1762, 0.9757, 0.7220},
+	ILLUMINANT_D55:         {1.1041, 0.9869, 0.8234},
+	ILLUMINANT_D75:         {0.9291, 1.0064, 1.1453},
+	ILLUMINANT_FLUORESCENT: {1.3403, 0.9430, 0.5628},
+}
+
+// adjustColors returns pixels of the rectangle of the image
+// with colors changed by color adjustment
+func adjustColors(img image.Image, rect image.Rectangle, ca ColorAdjustment) image.Image {
+	// image is lit by the illuminant, its white becomes D65 white
+	white, ok := illuminants[ca.IlluminantIndex]
+	if !ok {
+		white = [3]float64{1, 1, 1}
+	}
+	gamma := [3]float64{
+		float64(ca.RedGamma) / 10000,
+		float64(ca.GreenGamma) / 10000,
+		float64(ca.BlueGamma) / 10000,
+	}
+	black := float64(ca.ReferenceBlack) / 10000
+	scale := float64(ca.ReferenceWhite)/10000 - black
+	if scale <= 0 {
+		scale = 1
+	}
+	contrast := 1 + float64(ca.Contrast)/100
+	brightness := float64(ca.Brightness) / 200
+	colorfulness := 1 + float64(ca.Colorfulness)/100
+	// positive tint moves colors towards red, negative towards green
+	tint := [3]float64{1 + float64(ca.RedGreenTint)/200, 1 - float64(ca.RedGreenTint)/200, 1}
+
+	dst := image.NewRGBA(rect)
+	draw.Draw(dst, rect, img, rect.Min, draw.Src)
+	for j := 0; j < len(dst.Pix); j += 4 {
+		p := dst.Pix[j : j+4 : j+4]
+		if p[3] == 0 {
+			continue
+		}
+
+		// colors are adjusted without premultiplied alpha
+		a := float64(p[3]) / 0xff
+		v := [3]float64{float64(p[0]) / 0xff / a, float64(p[1]) / 0xff / a, float64(p[2]) / 0xff / a}
+
+		for i := range v {
+			v[i] = math.Pow(math.Pow(v[i], 2.2)/white[i], 1/2.2)
+			if gamma[i] > 0 {
+				v[i] = math.Pow(v[i], 1/gamma[i])
+			}
+			v[i] = (v[i] - black) / scale
+			v[i] = (v[i]-0.5)*contrast + 0.5 + brightness
+		}
+
+		l := 0.299*v[0] + 0.587*v[1] + 0.114*v[2]
+		for i := range v {
+			v[i] = (l + (v[i]-l)*colorfulness) * tint[i]
+			v[i] = math.Min(math.Max(v[i], 0), 1)
+			if ca.Values&CA_LOG_FILTER != 0 {
+				v[i] = math.Log1p(9*v[i]) / math.Log(10)
+			}
+			if ca.Values&CA_NEGATIVE != 0 {
+				v[i] = 1 - v[i]
+			}
+			p[i] = uint8(math.Round(v[i] * a * 0xff))
+		}
+	}
+	return dst
+}
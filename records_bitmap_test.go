@@ -0,0 +1,175 @@
+package emf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestReadImageBitfields(t *testing.T) {
+	tests := []struct {
+		name string
+		bmi  BitmapInfo
+		bits []interface{}
+		want []color.NRGBA
+	}{
+		{
+			name: "5-5-5",
+			bmi:  BitmapInfo{BitmapInfoHeader: BitmapInfoHeader{BitCount: 16}},
+			bits: []interface{}{[]uint16{0x7c00, 0x001f}},
+			want: []color.NRGBA{{0xff, 0, 0, 0xff}, {0, 0, 0xff, 0xff}},
+		},
+		{
+			name: "5-6-5 masks",
+			bmi: BitmapInfo{
+				BitmapInfoHeader: BitmapInfoHeader{BitCount: 16, Compression: BI_BITFIELDS},
+				RedMask:          0xf800,
+				GreenMask:        0x07e0,
+				BlueMask:         0x001f,
+			},
+			bits: []interface{}{[]uint16{0x07e0, 0x8410}},
+			want: []color.NRGBA{{0, 0xff, 0, 0xff}, {0x83, 0x81, 0x83, 0xff}},
+		},
+		{
+			name: "BGRX",
+			bmi:  BitmapInfo{BitmapInfoHeader: BitmapInfoHeader{BitCount: 32}},
+			bits: []interface{}{[]byte{1, 2, 3, 0, 4, 5, 6, 0}},
+			want: []color.NRGBA{{3, 2, 1, 0xff}, {6, 5, 4, 0xff}},
+		},
+		{
+			name: "alpha mask",
+			bmi: BitmapInfo{
+				BitmapInfoHeader: BitmapInfoHeader{BitCount: 32, Compression: BI_BITFIELDS},
+				RedMask:          0x000000ff,
+				GreenMask:        0x0000ff00,
+				BlueMask:         0x00ff0000,
+				AlphaMask:        0xff000000,
+			},
+			bits: []interface{}{[]uint32{0x80000001, 0xff030200}},
+			want: []color.NRGBA{{1, 0, 0, 0x80}, {0, 2, 3, 0xff}},
+		},
+		{
+			name: "alpha bit fields",
+			bmi: BitmapInfo{
+				BitmapInfoHeader: BitmapInfoHeader{BitCount: 32, Compression: BI_ALPHABITFIELDS},
+				RedMask:          0x00ff0000,
+				GreenMask:        0x0000ff00,
+				BlueMask:         0x000000ff,
+				AlphaMask:        0xff000000,
+			},
+			bits: []interface{}{[]uint32{0x40ff0000, 0x00000000}},
+			want: []color.NRGBA{{0xff, 0, 0, 0x40}, {0, 0, 0, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		r := &bitmapRecord{BmiSrc: tt.bmi, BitsSrc: encode(tt.bits...)}
+		r.BmiSrc.Width, r.BmiSrc.Height = int32(len(tt.want)), 1
+
+		img := r.readImage(nil)
+		if img == nil {
+			t.Errorf("%s: no image", tt.name)
+			continue
+		}
+		for x, want := range tt.want {
+			if got := color.NRGBAModel.Convert(img.At(x, 0)); got != want {
+				t.Errorf("%s: got pixel %d %v, want %v", tt.name, x, got, want)
+			}
+		}
+	}
+}
+
+func TestReadImageTopDown(t *testing.T) {
+	red, blue := color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}
+	white, black := color.NRGBA{0xff, 0xff, 0xff, 0xff}, color.NRGBA{0, 0, 0, 0xff}
+
+	tests := []struct {
+		name     string
+		bitCount uint16
+		height   int32
+		bits     []byte
+		want     []color.NRGBA
+	}{
+		{
+			name:     "24 bit bottom-up",
+			bitCount: BI_BITCOUNT_5,
+			height:   2,
+			bits:     []byte{0, 0, 0xff, 0, 0xff, 0, 0, 0},
+			want:     []color.NRGBA{blue, red},
+		},
+		{
+			name:     "24 bit top-down",
+			bitCount: BI_BITCOUNT_5,
+			height:   -2,
+			bits:     []byte{0, 0, 0xff, 0, 0xff, 0, 0, 0},
+			want:     []color.NRGBA{red, blue},
+		},
+		{
+			name:     "1 bit bottom-up",
+			bitCount: BI_BITCOUNT_1,
+			height:   2,
+			bits:     []byte{0x80, 0, 0, 0, 0, 0, 0, 0},
+			want:     []color.NRGBA{black, white},
+		},
+		{
+			name:     "1 bit top-down",
+			bitCount: BI_BITCOUNT_1,
+			height:   -2,
+			bits:     []byte{0x80, 0, 0, 0, 0, 0, 0, 0},
+			want:     []color.NRGBA{white, black},
+		},
+	}
+
+	for _, tt := range tests {
+		r := &bitmapRecord{
+			BmiSrc: BitmapInfo{BitmapInfoHeader: BitmapInfoHeader{
+				Width:    1,
+				Height:   tt.height,
+				BitCount: tt.bitCount,
+			}},
+			BitsSrc: tt.bits,
+		}
+
+		img := r.readImage(nil)
+		if img == nil {
+			t.Errorf("%s: no image", tt.name)
+			continue
+		}
+		for y, want := range tt.want {
+			if got := color.NRGBAModel.Convert(img.At(0, y)); got != want {
+				t.Errorf("%s: got line %d %v, want %v", tt.name, y, got, want)
+			}
+		}
+	}
+}
+
+func TestAdjustColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	copy(img.Pix, []uint8{0xff, 0x80, 0, 0xff, 0x40, 0x20, 0, 0x80, 0, 0, 0, 0})
+
+	identity := ColorAdjustment{
+		RedGamma:       10000,
+		GreenGamma:     10000,
+		BlueGamma:      10000,
+		ReferenceWhite: 10000,
+	}
+	negative := identity
+	negative.Values = CA_NEGATIVE
+
+	tests := []struct {
+		name string
+		ca   ColorAdjustment
+		want []uint8
+	}{
+		{"identity", identity, []uint8{0xff, 0x80, 0, 0xff, 0x40, 0x20, 0, 0x80, 0, 0, 0, 0}},
+		{"negative", negative, []uint8{0, 0x7f, 0xff, 0xff, 0x40, 0x60, 0x80, 0x80, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		got := adjustColors(img, img.Bounds(), tt.ca).(*image.RGBA)
+		if !bytes.Equal(got.Pix, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, got.Pix, tt.want)
+		}
+	}
+}
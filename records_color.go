@@ -0,0 +1,263 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+type CreatecolorspaceRecord struct {
+	Record
+	ihCS uint32
+	lcs  LogColorSpace
+}
+
+func readCreatecolorspaceRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &CreatecolorspaceRecord{}
+	r.Record = Record{Type: EMR_CREATECOLORSPACE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihCS); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.lcs, err = readLogColorSpace(reader, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *CreatecolorspaceRecord) Draw(ctx *context) {
+	ctx.objects[r.ihCS] = &r.lcs
+}
+
+type CreatecolorspacewRecord struct {
+	Record
+	ihCS    uint32
+	lcs     LogColorSpace
+	dwFlags uint32
+	cbData  uint32
+}
+
+func readCreatecolorspacewRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	r := &CreatecolorspacewRecord{}
+	r.Record = Record{Type: EMR_CREATECOLORSPACEW, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihCS); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.lcs, err = readLogColorSpace(reader, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.dwFlags); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.cbData); err != nil {
+		return nil, err
+	}
+
+	if int64(r.cbData) > int64(reader.Len()) {
+		return nil, fmt.Errorf("invalid color space data size %d", r.cbData)
+	}
+
+	data := make([]byte, r.cbData)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	if r.dwFlags&CREATECOLORSPACE_EMBEDDED != 0 {
+		r.lcs.Profile = data
+	}
+
+	// skip padding
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return r, err
+}
+
+func (r *CreatecolorspacewRecord) Draw(ctx *context) {
+	ctx.objects[r.ihCS] = &r.lcs
+}
+
+type SetcolorspaceRecord struct {
+	Record
+	ihCS uint32
+}
+
+func readSetcolorspaceRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &SetcolorspaceRecord{}
+	r.Record = Record{Type: EMR_SETCOLORSPACE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihCS); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *SetcolorspaceRecord) Draw(ctx *context) {
+	if cs, ok := ctx.objects[r.ihCS].(*LogColorSpace); ok {
+		ctx.colorSpace = cs
+	}
+}
+
+type DeletecolorspaceRecord struct {
+	Record
+	ihCS uint32
+}
+
+func readDeletecolorspaceRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &DeletecolorspaceRecord{}
+	r.Record = Record{Type: EMR_DELETECOLORSPACE, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihCS); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *DeletecolorspaceRecord) Draw(ctx *context) {
+	delete(ctx.objects, r.ihCS)
+}
+
+// profileRecord is a common part of records with ICM profile name and data
+type profileRecord struct {
+	Record
+	dwFlags        uint32
+	cbName, cbData uint32
+	// name of the profile file or color space
+	Name string
+	Data []byte
+}
+
+func (r *profileRecord) read(reader *bytes.Reader, wide bool) error {
+	if err := binary.Read(reader, binary.LittleEndian, &r.cbName); err != nil {
+		return err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.cbData); err != nil {
+		return err
+	}
+
+	// name and data are within the record
+	if int64(r.cbName)+int64(r.cbData) > int64(reader.Len()) {
+		return fmt.Errorf("invalid profile name size %d and data size %d", r.cbName, r.cbData)
+	}
+
+	name := make([]byte, r.cbName)
+	if _, err := io.ReadFull(reader, name); err != nil {
+		return err
+	}
+	if wide {
+		s := make([]uint16, len(name)/2)
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint16(name[2*i:])
+		}
+		r.Name = trimNull(string(utf16.Decode(s)))
+	} else {
+		r.Name = trimNull(decodeString(name, ANSI_CHARSET))
+	}
+
+	r.Data = make([]byte, r.cbData)
+	_, err := io.ReadFull(reader, r.Data)
+	return err
+}
+
+// skip skips padding at the end of the record
+func (r *profileRecord) skip(reader *bytes.Reader, offset int) error {
+	_, err := reader.Seek(int64(int(r.Size)-(offset-reader.Len())), io.SeekCurrent)
+	return err
+}
+
+// colorSpace returns color space of the profile
+func (r *profileRecord) colorSpace(embedded bool) *LogColorSpace {
+	cs := &LogColorSpace{ColorSpaceType: PROFILE_LINKED, Filename: r.Name}
+	if embedded {
+		cs.ColorSpaceType = PROFILE_EMBEDDED
+		cs.Profile = r.Data
+	}
+	return cs
+}
+
+type SeticmprofileRecord struct {
+	profileRecord
+}
+
+func readSeticmprofileRecord(reader *bytes.Reader, typ, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	r := &SeticmprofileRecord{}
+	r.Record = Record{Type: typ, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.dwFlags); err != nil {
+		return nil, err
+	}
+
+	if err := r.read(reader, typ == EMR_SETICMPROFILEW); err != nil {
+		return nil, err
+	}
+
+	return r, r.skip(reader, offset)
+}
+
+func readSeticmprofileaRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return readSeticmprofileRecord(reader, EMR_SETICMPROFILEA, size)
+}
+
+func readSeticmprofilewRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return readSeticmprofileRecord(reader, EMR_SETICMPROFILEW, size)
+}
+
+func (r *SeticmprofileRecord) Draw(ctx *context) {
+	ctx.colorSpace = r.colorSpace(r.dwFlags&SETICMPROFILE_EMBEDDED != 0)
+}
+
+type ColormatchtotargetwRecord struct {
+	profileRecord
+	dwAction uint32
+}
+
+func readColormatchtotargetwRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	r := &ColormatchtotargetwRecord{}
+	r.Record = Record{Type: EMR_COLORMATCHTOTARGETW, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.dwAction); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.dwFlags); err != nil {
+		return nil, err
+	}
+
+	if err := r.read(reader, true); err != nil {
+		return nil, err
+	}
+
+	return r, r.skip(reader, offset)
+}
+
+// Draw does nothing because colors are converted for the output image,
+// proofing them on the target device is not emulated.
+func (r *ColormatchtotargetwRecord) Draw(ctx *context) {}
+
+// trimNull returns string up to the first null character
+func trimNull(s string) string {
+	for i, c := range s {
+		if c == 0 {
+			return s[:i]
+		}
+	}
+	return s
+}
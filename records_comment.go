@@ -0,0 +1,254 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Comment records carry data which is not drawn. Public comments and
+// EMF spool comments are decoded into sub-records, EMF+ comments into
+// EMF+ records and other comments keep their data as is.
+
+type CommentRecord struct {
+	Record
+	DataSize uint32
+	// EMR_COMMENT_* identifier of the comment or the first
+	// 4 bytes of data of private comments
+	CommentIdentifier uint32
+	// EMR_COMMENT_* identifier of public comment
+	PublicCommentIdentifier uint32
+	// decoded comment, it's one of *CommentBegingroup, *CommentEndgroup,
+	// *CommentMultiformats, *CommentWindowsMetafile or *CommentEmfspool,
+	// it's nil for other comments
+	Comment interface{}
+	// EMF+ records of EMR_COMMENT_EMFPLUS comment
+	PlusRecords []PlusRecorder
+	// data of comments which are not decoded or failed to decode
+	Data []byte
+}
+
+func readCommentRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &CommentRecord{}
+	r.Record = Record{Type: EMR_COMMENT, Size: size}
+	start := reader.Len()
+
+	if size < 12 {
+		return nil, fmt.Errorf("invalid comment record size %d", size)
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.DataSize); err != nil {
+		return nil, err
+	}
+
+	// data can't go beyond the record
+	if r.DataSize > size-12 || int(r.DataSize) > reader.Len() {
+		return nil, fmt.Errorf("invalid comment data size %d", r.DataSize)
+	}
+
+	data := make([]byte, r.DataSize)
+	reader.Read(data)
+
+	if err := r.decode(data); err != nil {
+		// malformed comment is kept undecoded, it's not drawn anyway
+		fmt.Fprintf(os.Stderr, "emf: unable to decode comment: %v\n", err)
+		r.Comment = nil
+		r.Data = data
+	}
+
+	// skip the rest of record data
+	reader.Seek(int64(r.Size-8)-int64(start-reader.Len()), io.SeekCurrent)
+	return r, nil
+}
+
+// decode decodes comment data by its identifier
+func (r *CommentRecord) decode(data []byte) error {
+	if len(data) < 4 {
+		r.Data = data
+		return nil
+	}
+
+	r.CommentIdentifier = binary.LittleEndian.Uint32(data)
+	reader := bytes.NewReader(data[4:])
+
+	var err error
+	switch r.CommentIdentifier {
+	case EMR_COMMENT_EMFPLUS:
+		// records read before an invalid one are kept
+		r.PlusRecords, err = readPlusRecords(data[4:])
+		return err
+	case EMR_COMMENT_EMFSPOOL:
+		r.Comment, err = readCommentEmfspool(reader)
+		return err
+	case EMR_COMMENT_PUBLIC:
+		if err := binary.Read(reader, binary.LittleEndian, &r.PublicCommentIdentifier); err != nil {
+			return err
+		}
+
+		switch r.PublicCommentIdentifier {
+		case EMR_COMMENT_BEGINGROUP:
+			r.Comment, err = readCommentBegingroup(reader)
+			return err
+		case EMR_COMMENT_ENDGROUP:
+			r.Comment = &CommentEndgroup{}
+			return nil
+		case EMR_COMMENT_MULTIFORMATS:
+			r.Comment, err = readCommentMultiformats(reader, data)
+			return err
+		case EMR_COMMENT_WINDOWS_METAFILE:
+			r.Comment, err = readCommentWindowsMetafile(reader)
+			return err
+		}
+	}
+
+	r.Data = data
+	return nil
+}
+
+// CommentBegingroup starts a group of drawing records
+type CommentBegingroup struct {
+	// bounds of the group in logical units
+	Rectangle   RectL
+	Description string
+}
+
+func readCommentBegingroup(reader *bytes.Reader) (*CommentBegingroup, error) {
+	r := &CommentBegingroup{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Rectangle); err != nil {
+		return nil, err
+	}
+
+	var n uint32
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if int(n) > reader.Len()/2 {
+		return nil, fmt.Errorf("invalid group description length %d", n)
+	}
+
+	var err error
+	r.Description, err = readWideString(reader, int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CommentEndgroup ends a group of drawing records
+type CommentEndgroup struct{}
+
+// EmrFormat is a picture in alternate format of multiformats comment
+type EmrFormat struct {
+	// ENHMETA_SIGNATURE or EPS_SIGNATURE
+	Signature uint32
+	Version   uint32
+	Data      []byte
+}
+
+// CommentMultiformats contains picture in several formats,
+// records of EMF format are not parsed
+type CommentMultiformats struct {
+	// bounds of the picture in logical units
+	OutputRect RectL
+	Formats    []EmrFormat
+}
+
+// readCommentMultiformats reads formats with data
+// at offsets from the beginning of comment data
+func readCommentMultiformats(reader *bytes.Reader, data []byte) (*CommentMultiformats, error) {
+	r := &CommentMultiformats{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.OutputRect); err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	if int(count) > reader.Len()/16 {
+		return nil, fmt.Errorf("invalid number of formats %d", count)
+	}
+
+	for i := 0; i < int(count); i++ {
+		var f struct {
+			Signature, Version, SizeData, OffData uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+
+		if uint64(f.OffData)+uint64(f.SizeData) > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid format data offset %d", f.OffData)
+		}
+
+		r.Formats = append(r.Formats, EmrFormat{
+			Signature: f.Signature,
+			Version:   f.Version,
+			Data:      data[f.OffData : f.OffData+f.SizeData],
+		})
+	}
+
+	return r, nil
+}
+
+// CommentWindowsMetafile contains WMF metafile
+type CommentWindowsMetafile struct {
+	Version  uint16
+	Checksum uint16
+	Flags    uint32
+	// WMF metafile data
+	WinMetafile []byte
+}
+
+func readCommentWindowsMetafile(reader *bytes.Reader) (*CommentWindowsMetafile, error) {
+	r := &CommentWindowsMetafile{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Version); err != nil {
+		return nil, err
+	}
+
+	// Reserved
+	reader.Seek(2, io.SeekCurrent)
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Checksum); err != nil {
+		return nil, err
+	}
+
+	// Reserved
+	reader.Seek(2, io.SeekCurrent)
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Flags); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.WinMetafile, err = readPlusBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CommentEmfspool contains EMF spool records
+type CommentEmfspool struct {
+	EMFSpoolRecordIdentifier uint32
+	// EMF spool records, they are not parsed
+	Records []byte
+}
+
+func readCommentEmfspool(reader *bytes.Reader) (*CommentEmfspool, error) {
+	r := &CommentEmfspool{}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.EMFSpoolRecordIdentifier); err != nil {
+		return nil, err
+	}
+
+	r.Records = readRest(reader)
+	return r, nil
+}
@@ -0,0 +1,104 @@
+package emf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadCommentRecord(t *testing.T) {
+	// comment record with data size and data
+	comment := func(size uint32, dataSize uint32, data ...interface{}) []byte {
+		return append(encode(uint32(EMR_COMMENT), size, dataSize), encode(data...)...)
+	}
+	public := func(id uint32, data ...interface{}) []byte {
+		b := encode(uint32(EMR_COMMENT_PUBLIC), id)
+		b = append(b, encode(data...)...)
+		return comment(uint32(12+len(b)), uint32(len(b)), b)
+	}
+	plus := func(recs ...interface{}) []byte {
+		b := append(encode(uint32(EMR_COMMENT_EMFPLUS)), encode(recs...)...)
+		return comment(uint32(12+len(b)), uint32(len(b)), b)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		// decoded comment is expected
+		decoded bool
+		// number of EMF+ records
+		plusRecords int
+		isErr       bool
+	}{
+		{
+			name:    "begin group",
+			data:    public(EMR_COMMENT_BEGINGROUP, RectL{1, 2, 3, 4}, uint32(2), []uint16{'a', 'b'}),
+			decoded: true,
+		},
+		{
+			name: "begin group with invalid description length",
+			data: public(EMR_COMMENT_BEGINGROUP, RectL{1, 2, 3, 4}, uint32(1000), []uint16{'a', 'b'}),
+		},
+		{
+			name: "multiformats with invalid data offset",
+			data: public(EMR_COMMENT_MULTIFORMATS, RectL{}, uint32(1),
+				uint32(ENHMETA_SIGNATURE), uint32(1), uint32(4), uint32(1000)),
+		},
+		{
+			name: "truncated windows metafile",
+			data: public(EMR_COMMENT_WINDOWS_METAFILE, uint16(0x300)),
+		},
+		{
+			name: "invalid EMF+ record",
+			data: plus(PlusRecord{EMFPLUS_FILLRECTS, PLUSFLAG_SOLIDCOLOR, 36, 24},
+				uint32(0xff0000ff), uint32(1), PlusRectF{10, 10, 30, 30},
+				PlusRecord{EMFPLUS_CLEAR, 0, 400, 4}, uint32(0xff0000ff)),
+			plusRecords: 2,
+		},
+		{
+			name:  "data size beyond the record",
+			data:  comment(16, 8, uint32(0x6c6c6568), uint32(0)),
+			isErr: true,
+		},
+		{
+			name:  "record too short",
+			data:  comment(8, 0),
+			isErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		// comment is followed by another record which has to be read
+		data := append(tt.data, encode(uint32(EMR_SETBKMODE), uint32(12), uint32(TRANSPARENT))...)
+		reader := bytes.NewReader(data)
+
+		rec, err := readRecord(reader)
+		if tt.isErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		c := rec.(*CommentRecord)
+		if tt.decoded {
+			if c.Comment == nil || c.Data != nil {
+				t.Errorf("%s: got comment %#v with data % x, want decoded comment", tt.name, c.Comment, c.Data)
+			}
+		} else if c.Comment != nil || c.Data == nil {
+			t.Errorf("%s: got comment %#v with data % x, want raw data", tt.name, c.Comment, c.Data)
+		}
+		if len(c.PlusRecords) != tt.plusRecords {
+			t.Errorf("%s: got %d EMF+ records, want %d", tt.name, len(c.PlusRecords), tt.plusRecords)
+		}
+
+		if rec, err := readRecord(reader); err != nil {
+			t.Errorf("%s: next record: %v", tt.name, err)
+		} else if _, ok := rec.(*SetbkmodeRecord); !ok {
+			t.Errorf("%s: got next record %T", tt.name, rec)
+		}
+	}
+}
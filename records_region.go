@@ -0,0 +1,320 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+
+	"github.com/llgcode/draw2d"
+)
+
+// regionRecord is a common part of region records
+type regionRecord struct {
+	Record
+	Bounds      RectL
+	RgnDataSize uint32
+}
+
+func readRegionRecord(reader *bytes.Reader, typ, size uint32) (regionRecord, error) {
+	r := regionRecord{}
+	r.Record = Record{Type: typ, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Bounds); err != nil {
+		return r, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.RgnDataSize); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// readRgnData reads region data and skips the rest of the record
+func readRgnData(reader *bytes.Reader, offset int, size uint32) (Region, error) {
+	rgn, err := readRegion(reader)
+	if err != nil {
+		return rgn, err
+	}
+
+	_, err = reader.Seek(int64(int(size)-(offset-reader.Len())), io.SeekCurrent)
+	return rgn, err
+}
+
+// regionMask returns mask of the region in logical units
+func (ctx *context) regionMask(rgn Region) *image.Alpha {
+	return ctx.pathMask(draw2d.FillRuleWinding, rgn.path())
+}
+
+type FillrgnRecord struct {
+	regionRecord
+	ihBrush uint32
+	RgnData Region
+}
+
+func readFillrgnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &FillrgnRecord{}
+	r.regionRecord, err = readRegionRecord(reader, EMR_FILLRGN, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihBrush); err != nil {
+		return nil, err
+	}
+
+	r.RgnData, err = readRgnData(reader, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FillrgnRecord) Draw(ctx *context) {
+	if c, ok := ctx.brushColor(r.ihBrush); ok {
+		ctx.fillMask(ctx.regionMask(r.RgnData), c)
+	}
+}
+
+type FramergnRecord struct {
+	regionRecord
+	ihBrush       uint32
+	Width, Height int32
+	RgnData       Region
+}
+
+func readFramergnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &FramergnRecord{}
+	r.regionRecord, err = readRegionRecord(reader, EMR_FRAMERGN, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.ihBrush); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Width); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Height); err != nil {
+		return nil, err
+	}
+
+	r.RgnData, err = readRgnData(reader, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FramergnRecord) Draw(ctx *context) {
+	c, ok := ctx.brushColor(r.ihBrush)
+	if !ok {
+		return
+	}
+
+	// frame size in device units
+	tr := ctx.GetMatrixTransform()
+	dx := int(math.Max(math.Round(math.Abs(float64(r.Width))*math.Hypot(tr[0], tr[1])), 1))
+	dy := int(math.Max(math.Round(math.Abs(float64(r.Height))*math.Hypot(tr[2], tr[3])), 1))
+
+	mask := ctx.regionMask(r.RgnData)
+	ctx.fillMask(subtractMask(mask, erodeMask(mask, dx, dy)), c)
+}
+
+type InvertrgnRecord struct {
+	regionRecord
+	RgnData Region
+}
+
+func readInvertrgnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &InvertrgnRecord{}
+	r.regionRecord, err = readRegionRecord(reader, EMR_INVERTRGN, size)
+	if err != nil {
+		return nil, err
+	}
+
+	r.RgnData, err = readRgnData(reader, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *InvertrgnRecord) Draw(ctx *context) {
+	if ctx.discard {
+		return
+	}
+
+	img, ok := ctx.img.(*image.RGBA)
+	if !ok {
+		return
+	}
+
+	mask := intersectMask(ctx.clipMask(), ctx.regionMask(r.RgnData))
+	for i, a := range mask.Pix {
+		// region pixels are inverted entirely
+		if a < 0x80 {
+			continue
+		}
+		p := img.Pix[4*i : 4*i+3]
+		p[0], p[1], p[2] = 0xff-p[0], 0xff-p[1], 0xff-p[2]
+	}
+}
+
+type PaintrgnRecord struct {
+	regionRecord
+	RgnData Region
+}
+
+func readPaintrgnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	var err error
+	r := &PaintrgnRecord{}
+	r.regionRecord, err = readRegionRecord(reader, EMR_PAINTRGN, size)
+	if err != nil {
+		return nil, err
+	}
+
+	r.RgnData, err = readRgnData(reader, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *PaintrgnRecord) Draw(ctx *context) {
+	ctx.Save()
+	ctx.BeginPath()
+	ctx.SetFillRule(draw2d.FillRuleWinding)
+	ctx.Fill(r.RgnData.path())
+	ctx.Restore()
+}
+
+type ExcludecliprectRecord struct {
+	Record
+	Clip RectL
+}
+
+func readExcludecliprectRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &ExcludecliprectRecord{}
+	r.Record = Record{Type: EMR_EXCLUDECLIPRECT, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Clip); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *ExcludecliprectRecord) Draw(ctx *context) {
+	ctx.combineClip(ctx.rectMask(r.Clip), RGN_DIFF)
+}
+
+type ExtselectcliprgnRecord struct {
+	Record
+	RgnDataSize uint32
+	RegionMode  uint32
+	// region in device units, it's not present
+	// when the clipping region is reset to default
+	RgnData *Region
+}
+
+func readExtselectcliprgnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	offset := reader.Len() + 8
+
+	r := &ExtselectcliprgnRecord{}
+	r.Record = Record{Type: EMR_EXTSELECTCLIPRGN, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.RgnDataSize); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.RegionMode); err != nil {
+		return nil, err
+	}
+
+	if r.RgnDataSize > 0 {
+		rgn, err := readRgnData(reader, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		r.RgnData = &rgn
+	}
+
+	return r, nil
+}
+
+func (r *ExtselectcliprgnRecord) Draw(ctx *context) {
+	if r.RgnData == nil {
+		if r.RegionMode == RGN_COPY {
+			ctx.setClip(nil)
+		}
+		return
+	}
+
+	ctx.combineClip(ctx.deviceMask(*r.RgnData), r.RegionMode)
+}
+
+type OffsetcliprgnRecord struct {
+	Record
+	Offset PointL
+}
+
+func readOffsetcliprgnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	r := &OffsetcliprgnRecord{}
+	r.Record = Record{Type: EMR_OFFSETCLIPRGN, Size: size}
+
+	if err := binary.Read(reader, binary.LittleEndian, &r.Offset); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *OffsetcliprgnRecord) Draw(ctx *context) {
+	if ctx.clip == nil {
+		return
+	}
+
+	// offset is in logical units
+	tr := ctx.GetMatrixTransform()
+	x, y := float64(r.Offset.X), float64(r.Offset.Y)
+	dx := int(math.Round(tr[0]*x + tr[2]*y))
+	dy := int(math.Round(tr[1]*x + tr[3]*y))
+
+	ctx.setClip(offsetMask(ctx.clip, dx, dy))
+}
+
+type SetmetargnRecord struct {
+	Record
+}
+
+func readSetmetargnRecord(reader *bytes.Reader, size uint32) (Recorder, error) {
+	return &SetmetargnRecord{Record{Type: EMR_SETMETARGN, Size: size}}, nil
+}
+
+func (r *SetmetargnRecord) Draw(ctx *context) {
+	// meta region is intersected with clipping region
+	// which is reset to default one
+	ctx.metaClip = intersectMask(ctx.metaClip, ctx.clip)
+	ctx.setClip(nil)
+}
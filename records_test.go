@@ -0,0 +1,168 @@
+package emf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadSmalltextoutRecord(t *testing.T) {
+	rect := RectL{1, 2, 3, 4}
+
+	tests := []struct {
+		name    string
+		options uint32
+		chars   uint32
+		data    []interface{}
+		text    string
+		glyphs  []uint16
+		rect    RectL
+		invalid bool
+	}{
+		{
+			name:  "wide characters",
+			chars: 2,
+			data:  []interface{}{rect, []uint16{'h', 'i'}},
+			text:  "hi",
+			rect:  rect,
+		},
+		{
+			name:    "small characters without rectangle",
+			options: ETO_SMALL_CHARS | ETO_NO_RECT,
+			chars:   2,
+			data:    []interface{}{[]byte{'h', 'i', 0, 0}},
+			text:    "hi",
+		},
+		{
+			name:    "small glyph indices",
+			options: ETO_SMALL_CHARS | ETO_GLYPH_INDEX | ETO_NO_RECT,
+			chars:   3,
+			data:    []interface{}{[]byte{7, 8, 9, 0}},
+			glyphs:  []uint16{7, 8, 9},
+		},
+		{
+			name:    "count exceeds record",
+			options: ETO_NO_RECT,
+			chars:   1000,
+			data:    []interface{}{[]uint16{'h', 'i'}},
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		data := encode(tt.data...)
+		rec := append(encode(uint32(EMR_SMALLTEXTOUT), uint32(36+len(data)),
+			int32(10), int32(20), tt.chars, tt.options, uint32(GM_COMPATIBLE), float32(1), float32(1)),
+			data...)
+		reader := bytes.NewReader(rec)
+
+		r, err := readRecord(reader)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		text := r.(*SmalltextoutRecord).Text
+		if text.Reference != (PointL{10, 20}) || text.Options != tt.options || text.Rectangle != tt.rect {
+			t.Errorf("%s: got %+v", tt.name, text)
+		}
+		if text.OutputString != tt.text || !reflect.DeepEqual(text.Glyphs, tt.glyphs) {
+			t.Errorf("%s: got string %q and glyphs %v, want %q and %v",
+				tt.name, text.OutputString, text.Glyphs, tt.text, tt.glyphs)
+		}
+		if reader.Len() != 0 {
+			t.Errorf("%s: %d bytes of record left", tt.name, reader.Len())
+		}
+	}
+}
+
+func TestReadPolytextoutRecord(t *testing.T) {
+	// text object with rectangle and without spacing
+	object := func(x int32, offString uint32) []interface{} {
+		return []interface{}{PointL{x, 20}, uint32(2), offString, uint32(0), RectL{}, uint32(0)}
+	}
+	// two text objects are followed by their strings at offsets 120 and 124
+	objects := encode(append(object(10, 120), object(30, 124)...)...)
+
+	tests := []struct {
+		name     string
+		typ      uint32
+		cStrings uint32
+		strings  interface{}
+		texts    []string
+		invalid  bool
+	}{
+		{
+			name:     "wide strings",
+			typ:      EMR_POLYTEXTOUTW,
+			cStrings: 2,
+			strings:  []uint16{'a', 'b', 'c', 'd'},
+			texts:    []string{"ab", "cd"},
+		},
+		{
+			name:     "ansi strings",
+			typ:      EMR_POLYTEXTOUTA,
+			cStrings: 2,
+			strings:  []byte{'a', 'b', 0, 0, 'c', 'd', 0, 0},
+			texts:    []string{"ab", "cd"},
+		},
+		{
+			name:     "no strings",
+			typ:      EMR_POLYTEXTOUTW,
+			cStrings: 0,
+			strings:  []uint16{'a', 'b', 'c', 'd'},
+			texts:    []string{},
+		},
+		{
+			name:     "count exceeds record",
+			typ:      EMR_POLYTEXTOUTW,
+			cStrings: 1000,
+			strings:  []uint16{'a', 'b', 'c', 'd'},
+			invalid:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		data := append(objects[:len(objects):len(objects)], encode(tt.strings)...)
+		rec := append(encode(tt.typ, uint32(40+len(data)),
+			RectL{}, uint32(GM_COMPATIBLE), float32(1), float32(1), tt.cStrings),
+			data...)
+		reader := bytes.NewReader(rec)
+
+		r, err := readRecord(reader)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var texts []EmrText
+		switch r := r.(type) {
+		case *PolytextoutaRecord:
+			texts = r.aEmrTexts
+		case *PolytextoutwRecord:
+			texts = r.wEmrTexts
+		}
+		got := make([]string, len(texts))
+		for i, text := range texts {
+			got[i] = text.OutputString
+		}
+		if !reflect.DeepEqual(got, tt.texts) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.texts)
+		}
+		if reader.Len() != 0 {
+			t.Errorf("%s: %d bytes of record left", tt.name, reader.Len())
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package emf
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// height of the font in logical units when LogFont height is zero
+const defaultFontHeight = 12
+
+const (
+	// slant angle of synthesized italic font
+	syntheticSlant = 12 * math.Pi / 180
+	// outline width of synthesized bold font relative to em size
+	syntheticBoldness = 1.0 / 32
+)
+
+// Fonts are looked up in draw2d font cache (see draw2d.SetFontFolder,
+// draw2d.RegisterFont and draw2d.SetFontCache) by face name, family and
+// style. Go regular font is used for faces that are not found there.
+// Underline and strikeout are placed with metrics of the font when its data
+// is known, it's known for Go font and for caches with
+// LoadData(draw2d.FontData) ([]byte, error) method.
+var goFont = goregular.TTF
+
+// fontData returns draw2d font description for LogFont
+func fontData(lf LogFont) draw2d.FontData {
+	fd := draw2d.FontData{
+		// vertical fonts have the same name with @ prefix
+		Name:   strings.TrimPrefix(lf.Facename, "@"),
+		Family: draw2d.FontFamilySans,
+		Style:  draw2d.FontStyleNormal,
+	}
+
+	switch uint8(lf.PitchAndFamily) >> 4 {
+	case FF_ROMAN:
+		fd.Family = draw2d.FontFamilySerif
+	case FF_MODERN:
+		fd.Family = draw2d.FontFamilyMono
+	}
+
+	if lf.PitchAndFamily&0x03 == FIXED_PITCH {
+		fd.Family = draw2d.FontFamilyMono
+	}
+
+	if lf.Weight >= FW_SEMIBOLD {
+		fd.Style |= draw2d.FontStyleBold
+	}
+
+	if lf.Italic != 0 {
+		fd.Style |= draw2d.FontStyleItalic
+	}
+
+	return fd
+}
+
+// loadedFont is a font resolved for draw2d font description
+type loadedFont struct {
+	*truetype.Font
+	// styles missing in the font which have to be synthesized
+	synthetic draw2d.FontStyle
+	lines     lineMetrics
+	// face distances from the baseline in font units, both are positive
+	faceAscent, faceDescent float64
+}
+
+// fontDataLoader is implemented by font caches which also return TrueType
+// data of the fonts, truetype.Font doesn't keep post and OS/2 tables with
+// underline and strikeout metrics
+type fontDataLoader interface {
+	LoadData(draw2d.FontData) ([]byte, error)
+}
+
+// lineMetrics are underline and strikeout metrics in font units, the
+// positions are tops of the lines above the baseline, the metrics
+// missing in the font are zero
+type lineMetrics struct {
+	underlinePosition, underlineThickness float64
+	strikeoutPosition, strikeoutSize      float64
+}
+
+// readLineMetrics reads underline metrics from post table and strikeout
+// metrics from OS/2 table of TrueType font or the first font of collection
+func readLineMetrics(ttf []byte) (m lineMetrics) {
+	u16 := func(b []byte, i int) uint16 { return binary.BigEndian.Uint16(b[i:]) }
+	u32 := func(b []byte, i int) uint32 { return binary.BigEndian.Uint32(b[i:]) }
+
+	offset := 0
+	if len(ttf) >= 16 && string(ttf[:4]) == "ttcf" {
+		offset = int(u32(ttf, 12))
+	}
+	if offset < 0 || len(ttf)-offset < 12 {
+		return m
+	}
+
+	n := int(u16(ttf, offset+4))
+	for i := 0; i < n; i++ {
+		x := offset + 12 + 16*i
+		if x+16 > len(ttf) {
+			break
+		}
+		start, length := int64(u32(ttf, x+8)), int64(u32(ttf, x+12))
+		if start+length > int64(len(ttf)) {
+			continue
+		}
+		table := ttf[start : start+length]
+
+		switch string(ttf[x : x+4]) {
+		case "post":
+			if len(table) >= 12 {
+				m.underlinePosition = float64(int16(u16(table, 8)))
+				m.underlineThickness = float64(int16(u16(table, 10)))
+			}
+		case "OS/2":
+			if len(table) >= 30 {
+				m.strikeoutSize = float64(int16(u16(table, 26)))
+				m.strikeoutPosition = float64(int16(u16(table, 28)))
+			}
+		}
+	}
+
+	return m
+}
+
+// fontKey identifies font resolved for LogFont
+type fontKey struct {
+	draw2d.FontData
+	fullName string
+}
+
+// loadFont returns font from draw2d font cache or Go regular font. Full
+// name of the font is tried first when it's known. Regular face of the
+// font is used when there is no face with requested style.
+func (ctx *context) loadFont(lf LogFont) loadedFont {
+	fd := fontData(lf)
+	key := fontKey{FontData: fd, fullName: lf.FullName}
+	if f, ok := ctx.fonts[key]; ok {
+		return f
+	}
+
+	var f loadedFont
+	// font description of the loaded font
+	var loaded draw2d.FontData
+	if lf.FullName != "" && lf.FullName != fd.Name {
+		full := fd
+		full.Name = lf.FullName
+		if ff, err := ctx.FontCache.Load(full); err == nil {
+			f.Font, loaded = ff, full
+		}
+	}
+
+	if f.Font == nil {
+		if ff, err := ctx.FontCache.Load(fd); err == nil {
+			f.Font, loaded = ff, fd
+		} else if fd.Style != draw2d.FontStyleNormal {
+			regular := fd
+			regular.Style = draw2d.FontStyleNormal
+			if ff, err := ctx.FontCache.Load(regular); err == nil {
+				f = loadedFont{Font: ff, synthetic: fd.Style}
+				loaded = regular
+			}
+		}
+	}
+
+	if f.Font == nil {
+		// Go font is known to be valid, its styles are synthesized
+		f = loadedFont{synthetic: fd.Style, lines: readLineMetrics(goFont)}
+		f.Font, _ = truetype.Parse(goFont)
+	} else if l, ok := ctx.FontCache.(fontDataLoader); ok {
+		if data, err := l.LoadData(loaded); err == nil {
+			f.lines = readLineMetrics(data)
+		}
+	}
+
+	// face metrics with size of one em in font units
+	upem := float64(f.FUnitsPerEm())
+	m := truetype.NewFace(f.Font, &truetype.Options{Size: upem, DPI: 72}).Metrics()
+	f.faceAscent, f.faceDescent = float64(m.Ascent)/64, float64(m.Descent)/64
+
+	ctx.fonts[key] = f
+	return f
+}
+
+// textFont is a font selected into device context resolved to TrueType font
+type textFont struct {
+	loadedFont
+	LogFont
+	// scale converts font units to logical units
+	scale float64
+	// horizontal scale of glyphs requested with LogFont width
+	xscale float64
+	// distances from the baseline in logical units, both are positive
+	ascent, descent float64
+}
+
+func (ctx *context) textFont() *textFont {
+	f := ctx.loadFont(ctx.font)
+	upem := float64(f.FUnitsPerEm())
+	ascent, descent := f.faceAscent, f.faceDescent
+
+	// negative height is the em height of characters and
+	// positive one is the height of character cells
+	var em float64
+	switch h := float64(ctx.font.Height); {
+	case h < 0:
+		em = -h
+	case h > 0 && ascent+descent > 0:
+		em = h * upem / (ascent + descent)
+	default:
+		em = defaultFontHeight
+	}
+
+	tf := &textFont{
+		loadedFont: f,
+		LogFont:    ctx.font,
+		scale:      em / upem,
+		xscale:     1,
+		ascent:     ascent * em / upem,
+		descent:    descent * em / upem,
+	}
+
+	// font aspect ratio is kept with aspect filtering
+	if ctx.font.Width != 0 && ctx.mapperFlags&ASPECT_FILTERING == 0 {
+		// width is the average width of characters
+		if avg := tf.averageWidth(); avg > 0 {
+			tf.xscale = math.Abs(float64(ctx.font.Width)) / avg
+		}
+	}
+
+	return tf
+}
+
+// averageWidth returns average advance of lowercase letters in logical units
+func (f *textFont) averageWidth() float64 {
+	var sum float64
+	var n int
+	for r := 'a'; r <= 'z'; r++ {
+		if i := f.Index(r); i != 0 {
+			sum += float64(f.HMetric(fixed.Int26_6(f.FUnitsPerEm()), i).AdvanceWidth)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n) * f.scale
+}
+
+// underline returns top of underline below the baseline and its thickness
+// in logical units, it's placed at the middle of descent with twentieth of
+// em thickness when the font has no post table
+func (f *textFont) underline() (y, thickness float64) {
+	if f.lines.underlineThickness > 0 {
+		return -f.lines.underlinePosition * f.scale,
+			math.Max(f.lines.underlineThickness*f.scale, 1)
+	}
+	return f.descent / 2, f.defaultThickness()
+}
+
+// strikeout returns top of strikeout above the baseline and its thickness
+// in logical units, it's centered in the middle of lowercase letters with
+// twentieth of em thickness when the font has no OS/2 table
+func (f *textFont) strikeout() (y, thickness float64) {
+	if f.lines.strikeoutSize > 0 {
+		return f.lines.strikeoutPosition * f.scale,
+			math.Max(f.lines.strikeoutSize*f.scale, 1)
+	}
+
+	thickness = f.defaultThickness()
+	buf := &truetype.GlyphBuf{}
+	err := buf.Load(f.Font, fixed.Int26_6(f.FUnitsPerEm())<<6, f.Index('x'), font.HintingNone)
+	if err != nil || buf.Bounds.Max.Y <= 0 {
+		return f.ascent/3 + thickness/2, thickness
+	}
+	return float64(buf.Bounds.Max.Y)/64/2*f.scale + thickness/2, thickness
+}
+
+// defaultThickness returns thickness of lines in logical units
+// for fonts without line metrics
+func (f *textFont) defaultThickness() float64 {
+	return math.Max(float64(f.FUnitsPerEm())/20*f.scale, 1)
+}
+
+// index returns glyph index of the character
+func (f *textFont) index(r rune) truetype.Index {
+	i := f.Index(r)
+	if i == 0 && r&0xFF00 == 0xF000 {
+		// symbol character in the font without symbol encoding
+		i = f.Index(r & 0xFF)
+	}
+	return i
+}
+
+// advance returns glyph advance width in logical units
+func (f *textFont) advance(i truetype.Index) float64 {
+	return float64(f.HMetric(fixed.Int26_6(f.FUnitsPerEm()), i).AdvanceWidth) * f.scale * f.xscale
+}
+
+// glyph adds outline of glyph placed at x, y in font units to the path
+func (f *textFont) glyph(path draw2d.PathBuilder, buf *truetype.GlyphBuf, i truetype.Index, x, y float64) {
+	// load glyph without scaling
+	if err := buf.Load(f.Font, fixed.Int26_6(f.FUnitsPerEm())<<6, i, font.HintingNone); err != nil {
+		return
+	}
+
+	e0 := 0
+	for _, e1 := range buf.Ends {
+		draw2dimg.DrawContour(path, buf.Points[e0:e1], x, y)
+		e0 = e1
+	}
+}
+
+// uprightGlyph adds outline of glyph turned by 90 degrees counterclockwise
+// to the path, its top is at x and its middle is in the middle of character
+// cell above y in font units
+func (f *textFont) uprightGlyph(path *draw2d.Path, buf *truetype.GlyphBuf, i truetype.Index, x, y float64) {
+	p := &draw2d.Path{}
+	f.glyph(p, buf, i, 0, 0)
+
+	ascent, descent := f.ascent/f.scale, f.descent/f.scale
+	adv := float64(f.HMetric(fixed.Int26_6(f.FUnitsPerEm()), i).AdvanceWidth)
+	tx, ty := x+ascent, y+(descent-ascent)/2+adv/2
+	for j := 0; j+1 < len(p.Points); j += 2 {
+		gx, gy := p.Points[j], p.Points[j+1]
+		p.Points[j], p.Points[j+1] = gy+tx, ty-gx
+	}
+
+	path.Components = append(path.Components, p.Components...)
+	path.Points = append(path.Points, p.Points...)
+}
+
+// fullWidth reports whether character is a full-width East Asian
+// character which is upright in vertical text
+func fullWidth(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303F || r >= 0xFF01 && r <= 0xFF60
+}
+
+// fillRect fills rectangle in logical units with the color
+func (ctx *context) fillRect(x1, y1, x2, y2 float64, c color.Color) {
+	ctx.Save()
+	ctx.BeginPath()
+	ctx.MoveTo(x1, y1)
+	ctx.LineTo(x2, y1)
+	ctx.LineTo(x2, y2)
+	ctx.LineTo(x1, y2)
+	ctx.Close()
+	ctx.SetFillRule(draw2d.FillRuleWinding)
+	ctx.SetFillColor(c)
+	ctx.Fill()
+	ctx.Restore()
+}
+
+// drawText draws text with selected font, text color and text alignment.
+// Background of the text is filled in OPAQUE background mode.
+func (ctx *context) drawText(t EmrText) {
+	f := ctx.textFont()
+
+	rc := t.Rectangle
+	if t.Options&ETO_OPAQUE != 0 {
+		ctx.fillRect(float64(rc.Left), float64(rc.Top),
+			float64(rc.Right), float64(rc.Bottom), ctx.bkColor)
+	}
+
+	if t.Options&ETO_CLIPPED != 0 {
+		clip := ctx.clip
+		ctx.setClip(intersectMask(clip, ctx.rectMask(rc)))
+		defer ctx.setClip(clip)
+	}
+
+	// vertical fonts have full-width characters turned upright
+	// on the baseline, characters of glyph indexes are unknown
+	vertical := strings.HasPrefix(ctx.font.Facename, "@")
+	var glyphs []truetype.Index
+	var upright []bool
+	if t.Options&ETO_GLYPH_INDEX != 0 {
+		glyphs = make([]truetype.Index, len(t.Glyphs))
+		upright = make([]bool, len(t.Glyphs))
+		for i, g := range t.Glyphs {
+			glyphs[i] = truetype.Index(g)
+		}
+	} else {
+		for _, r := range t.text(ctx.font.CharSet) {
+			glyphs = append(glyphs, f.index(r))
+			upright = append(upright, vertical && fullWidth(r))
+		}
+	}
+
+	dx, dy := t.OutputDx, t.OutputDy
+	if t.Options&ETO_REVERSE_INDEX_MAP != 0 {
+		// glyphs are stored from the end of the string
+		glyphs = reverseGlyphs(glyphs)
+		for i, j := 0, len(upright)-1; i < j; i, j = i+1, j-1 {
+			upright[i], upright[j] = upright[j], upright[i]
+		}
+		dx = reverseSpacing(dx)
+		dy = reverseSpacing(dy)
+	}
+
+	// text justification is applied when spacing is not set
+	var breakExtra, breakCount int32
+	if len(dx) == 0 && ctx.breakCount > 0 {
+		breakExtra, breakCount = ctx.breakExtra, ctx.breakCount
+	}
+	space := f.index(' ')
+
+	// glyph positions along the baseline and vertical offsets from it
+	pos := make([]float64, len(glyphs))
+	off := make([]float64, len(glyphs))
+	var width, height float64
+	for i := range glyphs {
+		pos[i], off[i] = width, height
+		if i < len(dx) {
+			width += float64(dx[i])
+		} else if upright[i] {
+			// upright glyphs advance by the height of character cell
+			width += f.ascent + f.descent
+		} else {
+			width += f.advance(glyphs[i])
+		}
+		if glyphs[i] == space && space != 0 && breakCount > 0 {
+			// extra space is distributed evenly between break characters
+			extra := breakExtra / breakCount
+			width += float64(extra)
+			breakExtra -= extra
+			breakCount--
+		}
+		if i < len(dy) {
+			// vertical spacing is directed upwards
+			height -= float64(dy[i])
+		}
+	}
+
+	if ctx.textAlign&TA_RTLREADING != 0 || t.Options&ETO_RTLREADING != 0 {
+		// first character is placed at the right end of the string
+		for i := range pos {
+			next := width
+			if i+1 < len(pos) {
+				next = pos[i+1]
+			}
+			pos[i] = width - next
+		}
+	}
+
+	x, y := float64(t.Reference.X), float64(t.Reference.Y)
+	if ctx.textAlign&TA_UPDATECP != 0 {
+		x, y = ctx.curX, ctx.curY
+	}
+
+	// Baseline of the text is the X axis of the text space. Glyphs are kept
+	// upright when Y axis of logical space points up. Vertical fonts use
+	// VTA_* constants, their values match TA_* ones with the same meaning
+	// along the baseline which goes downwards for such fonts when text is
+	// drawn with 2700 escapement, full-width glyphs are upright then.
+	m := draw2d.NewTranslationMatrix(x, y)
+	if ctx.GetMatrixTransform()[3] < 0 {
+		m.Scale(1, -1)
+	}
+	if ctx.layout&LAYOUT_RTL != 0 {
+		// glyphs are not mirrored by right-to-left layout
+		m.Scale(-1, 1)
+	}
+	m.Rotate(-float64(f.Escapement) * math.Pi / 1800)
+
+	// text origin relative to the reference point
+	var ox, oy float64
+	switch ctx.textAlign & TA_CENTER {
+	case TA_CENTER:
+		ox = -width / 2
+	case TA_RIGHT:
+		ox = -width
+	}
+	switch ctx.textAlign & TA_BASELINE {
+	case TA_BASELINE:
+	case TA_BOTTOM:
+		oy = -f.descent
+	default:
+		oy = f.ascent
+	}
+
+	if ctx.textAlign&TA_UPDATECP != 0 {
+		// current position is moved to the end of the string
+		// when it's a reference point for the left or right side of text
+		var advx, advy float64
+		switch ctx.textAlign & TA_CENTER {
+		case TA_LEFT:
+			advx, advy = width, height
+		case TA_RIGHT:
+			advx, advy = -width, -height
+		}
+		ctx.curX, ctx.curY = m.TransformPoint(advx, advy)
+	}
+
+	// glyph outlines in font units
+	outlines := &draw2d.Path{}
+	buf := &truetype.GlyphBuf{}
+	for i, g := range glyphs {
+		if upright[i] {
+			f.uprightGlyph(outlines, buf, g, pos[i]/(f.scale*f.xscale), off[i]/f.scale)
+		} else {
+			f.glyph(outlines, buf, g, pos[i]/(f.scale*f.xscale), off[i]/f.scale)
+		}
+	}
+
+	if ctx.inPath {
+		// outlines are added to the path bracket instead of being drawn
+		tr := m
+		tr.Translate(ox, oy)
+		if f.synthetic&draw2d.FontStyleItalic != 0 {
+			tr.Compose(draw2d.Matrix{1, 0, -math.Tan(syntheticSlant), 1, 0, 0})
+		}
+		tr.Scale(f.scale*f.xscale, f.scale)
+		tr.Transform(outlines.Points)
+		ctx.Current.Path.Components = append(ctx.Current.Path.Components, outlines.Components...)
+		ctx.Current.Path.Points = append(ctx.Current.Path.Points, outlines.Points...)
+		return
+	}
+
+	ctx.Save()
+	tr := ctx.GetMatrixTransform()
+	tr.Compose(m)
+	ctx.SetMatrixTransform(tr)
+	// baseline starts at the origin
+	ctx.Translate(ox, oy)
+
+	if ctx.bkMode == OPAQUE {
+		// character cells of the string
+		ctx.fillRect(0, -f.ascent, width, f.descent, ctx.bkColor)
+	}
+
+	if f.Underline != 0 {
+		y, th := f.underline()
+		ctx.fillRect(0, y, width, y+th, ctx.textColor)
+	}
+
+	ctx.Save()
+	if f.synthetic&draw2d.FontStyleItalic != 0 {
+		// oblique glyphs are slanted around the baseline
+		ctx.ComposeMatrixTransform(draw2d.Matrix{1, 0, -math.Tan(syntheticSlant), 1, 0, 0})
+	}
+
+	ctx.BeginPath()
+	ctx.Scale(f.scale*f.xscale, f.scale)
+	ctx.Current.Path = outlines
+
+	ctx.SetFillRule(draw2d.FillRuleWinding)
+	ctx.SetFillColor(ctx.textColor)
+	if f.synthetic&draw2d.FontStyleBold != 0 {
+		// emboldened glyphs are outlined
+		ctx.SetStrokeColor(ctx.textColor)
+		ctx.SetLineWidth(float64(f.FUnitsPerEm()) * syntheticBoldness)
+		ctx.SetLineJoin(draw2d.RoundJoin)
+		ctx.SetLineDash(nil, 0)
+		ctx.FillStroke()
+	} else {
+		ctx.Fill()
+	}
+	ctx.Restore()
+
+	if f.StrikeOut != 0 {
+		y, th := f.strikeout()
+		ctx.fillRect(0, -y, width, -y+th, ctx.textColor)
+	}
+	ctx.Restore()
+
+}
+
+func reverseGlyphs(s []truetype.Index) []truetype.Index {
+	r := make([]truetype.Index, len(s))
+	for i, g := range s {
+		r[len(s)-1-i] = g
+	}
+	return r
+}
+
+func reverseSpacing(s []int32) []int32 {
+	r := make([]int32, len(s))
+	for i, d := range s {
+		r[len(s)-1-i] = d
+	}
+	return r
+}